@@ -4,7 +4,6 @@ import (
 	b3 "behavior3go"
 	. "behavior3go/config"
 	. "behavior3go/core"
-	"time"
 )
 
 /**
@@ -42,7 +41,7 @@ func (this *Wait) Initialize(setting *BTNodeCfg) {
  * @param {Tick} tick A tick instance.
 **/
 func (this *Wait) OnOpen(tick *Tick) {
-	var startTime int64 = time.Now().UnixNano() / 1000000
+	var startTime int64 = tick.Now().UnixNano() / 1000000
 	tick.Blackboard.Set("startTime", startTime, tick.GetTree().GetID(), this.GetID())
 }
 
@@ -53,7 +52,7 @@ func (this *Wait) OnOpen(tick *Tick) {
  * @return {Constant} A state constant.
 **/
 func (this *Wait) OnTick(tick *Tick) b3.Status {
-	var currTime int64 = time.Now().UnixNano() / 1000000
+	var currTime int64 = tick.Now().UnixNano() / 1000000
 	var startTime = tick.Blackboard.GetInt64("startTime", tick.GetTree().GetID(), this.GetID())
 	//fmt.Println("wait:",this.GetTitle(),tick.GetLastSubTree(),"=>", currTime-startTime)
 	if currTime-startTime > this.endTime {
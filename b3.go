@@ -1,5 +1,7 @@
 package behavior3go
 
+import "strconv"
+
 //b3 define
 const (
 	VERSION = "0.2.0"
@@ -20,3 +22,17 @@ const (
 	RUNNING Status = 3
 	ERROR   Status = 4
 )
+
+func (s Status) String() string {
+	switch s {
+	case SUCCESS:
+		return "SUCCESS"
+	case FAILURE:
+		return "FAILURE"
+	case RUNNING:
+		return "RUNNING"
+	case ERROR:
+		return "ERROR"
+	}
+	return "Status(" + strconv.Itoa(int(s)) + ")"
+}
@@ -0,0 +1,102 @@
+package b3test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// tick 执行一次tick，之后推进树的FakeClock
+func tick(tree *BehaviorTree, target interface{}, bb *Blackboard) b3.Status {
+	status := tree.Tick(target, bb)
+	if clock, ok := tree.GetClock().(*FakeClock); ok && clock.Step != 0 {
+		clock.Advance(clock.Step)
+	}
+	return status
+}
+
+// visitListener 按进入顺序记录节点
+type visitListener struct {
+	BaseTickListener
+	nodes []Visit
+}
+
+func (this *visitListener) EnterNode(tick *Tick, node IBaseNode) {
+	this.nodes = append(this.nodes, Visit{Key: tick.NodeKey(node), Title: node.GetTitle(), Name: node.GetName()})
+}
+
+// Visit is a node executed during a tick.
+type Visit struct {
+	Key   string // see core.Tick.NodeKey
+	Title string
+	Name  string
+}
+
+func (this Visit) String() string {
+	if this.Title != "" {
+		return this.Title
+	}
+	return this.Name + "#" + this.Key
+}
+
+// Run ticks the tree n times and returns the statuses.
+func Run(tree *BehaviorTree, target interface{}, bb *Blackboard, n int) []b3.Status {
+	statuses := make([]b3.Status, n)
+	for i := range statuses {
+		statuses[i] = tick(tree, target, bb)
+	}
+	return statuses
+}
+
+// Visited ticks the tree once, and returns its status and the nodes
+// executed, in the order they were entered.
+func Visited(tree *BehaviorTree, target interface{}, bb *Blackboard) (b3.Status, []Visit) {
+	listener := &visitListener{}
+	tree.AddListener(listener)
+	defer tree.RemoveListener(listener)
+	status := tick(tree, target, bb)
+	return status, listener.nodes
+}
+
+// AssertStatusSequence ticks the tree once per status of want, and fails
+// the test if the ticks return other statuses.
+func AssertStatusSequence(t testing.TB, tree *BehaviorTree, target interface{}, bb *Blackboard, want []b3.Status) bool {
+	t.Helper()
+	got := Run(tree, target, bb, len(want))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tick %d returned %v: statuses %v, want %v", i, got[i], got, want)
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * AssertVisited ticks the tree once, and fails the test if the nodes
+ * executed, in the order they were entered, are not want. The nodes are
+ * given by title or by node key.
+**/
+func AssertVisited(t testing.TB, tree *BehaviorTree, target interface{}, bb *Blackboard, want ...string) bool {
+	t.Helper()
+	_, visits := Visited(tree, target, bb)
+	ok := len(visits) == len(want)
+	for i := 0; ok && i < len(want); i++ {
+		ok = want[i] == visits[i].Title || want[i] == visits[i].Key
+	}
+	if !ok {
+		t.Errorf("visited %s, want %s", formatVisits(visits), strings.Join(want, " "))
+	}
+	return ok
+}
+
+func formatVisits(visits []Visit) string {
+	names := make([]string, len(visits))
+	for i, v := range visits {
+		names[i] = fmt.Sprint(v)
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,44 @@
+package b3test
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock for `BehaviorTree.SetClock`. The time only changes
+// with Advance and Set, or by Step after every tick of the assertions.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	// Step is added to the time after every tick of AssertStatusSequence
+	// and AssertVisited, when the clock is the clock of the tree.
+	Step time.Duration
+}
+
+// NewFakeClock returns a clock at start, or at 2000-01-01 UTC if start is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &FakeClock{now: start}
+}
+
+func (this *FakeClock) Now() time.Time {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.now
+}
+
+// Advance moves the time forward by d.
+func (this *FakeClock) Advance(d time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.now = this.now.Add(d)
+}
+
+// Set sets the time.
+func (this *FakeClock) Set(now time.Time) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.now = now
+}
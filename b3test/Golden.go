@@ -0,0 +1,179 @@
+package b3test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// 加上包名前缀，不和测试程序自己的-update冲突
+var update = flag.Bool("b3test.update", false, "rewrite the golden files of b3test instead of comparing")
+
+/**
+ * Scenario is a run of a tree for golden tests: the tree is ticked Ticks
+ * times on a new blackboard, with scripted inputs. Its trace lists for
+ * every tick the inputs, the nodes executed with their status, indented by
+ * depth (the nodes of a subtree below their `tree` node), the writes of the
+ * nodes to the global and tree memory, the halted nodes and the status of
+ * the tick:
+ *
+ *     tick 0
+ *       input hp=10
+ *       1 root(Priority) RUNNING
+ *         2 fight(Sequence) FAILURE
+ *           3 see enemy(SeeEnemy) FAILURE
+ *         5 patrol(Patrol) RUNNING
+ *           set target="door"
+ *       => RUNNING
+**/
+type Scenario struct {
+	Target interface{}
+	Ticks  int
+	// Input are written to the global memory before the tick of their
+	// index, from 0.
+	Input map[int]map[string]interface{}
+	// Before is called before every tick, after the Input, to script the
+	// blackboard, the mocks or the clock. It can be nil.
+	Before func(tick int, bb *Blackboard)
+}
+
+// traceListener 按进入顺序记录节点和黑板的写入
+type traceListener struct {
+	BaseTickListener
+	lines []string
+	// 进入的节点在lines中的位置
+	stack []int
+}
+
+func (this *traceListener) indent() string {
+	return strings.Repeat("  ", len(this.stack)+1)
+}
+
+func (this *traceListener) EnterNode(tick *Tick, node IBaseNode) {
+	this.lines = append(this.lines, fmt.Sprintf("%s%s %s(%s)", this.indent(), tick.NodeKey(node), node.GetTitle(), node.GetName()))
+	this.stack = append(this.stack, len(this.lines)-1)
+}
+
+func (this *traceListener) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	if len(this.stack) > 0 {
+		this.lines[this.stack[len(this.stack)-1]] += " " + status.String()
+	}
+}
+
+func (this *traceListener) ExitNode(tick *Tick, node IBaseNode) {
+	if len(this.stack) > 0 {
+		this.stack = this.stack[:len(this.stack)-1]
+	}
+}
+
+func (this *traceListener) HaltNode(tick *Tick, node IBaseNode) {
+	this.lines = append(this.lines, fmt.Sprintf("%shalt %s %s(%s)", this.indent(), tick.NodeKey(node), node.GetTitle(), node.GetName()))
+}
+
+func (this *traceListener) write(prefix string, change BlackboardChange) {
+	// 节点内存是节点的实现细节，tick中节点之外的写入是树的内部状态，不记录
+	if change.NodeScope != "" || (prefix == "set" && len(this.stack) == 0) {
+		return
+	}
+	key := change.Key
+	if change.TreeScope != "" {
+		key = "tree." + key
+	}
+	if change.Removed {
+		this.lines = append(this.lines, fmt.Sprintf("%s%s %s removed", this.indent(), prefix, key))
+		return
+	}
+	value, err := json.Marshal(change.New)
+	if err != nil {
+		value = []byte(fmt.Sprintf("%v", change.New))
+	}
+	this.lines = append(this.lines, fmt.Sprintf("%s%s %s=%s", this.indent(), prefix, key, value))
+}
+
+// Trace runs the scenario on tree and returns its trace.
+func (this *Scenario) Trace(tree *BehaviorTree) string {
+	bb := NewBlackboard()
+	listener := &traceListener{}
+	prefix := "input"
+	watch := bb.WatchAll(func(change BlackboardChange) { listener.write(prefix, change) })
+	defer bb.Unwatch(watch)
+	tree.AddListener(listener)
+	defer tree.RemoveListener(listener)
+
+	for i := 0; i < this.Ticks; i++ {
+		listener.lines = append(listener.lines, fmt.Sprintf("tick %d", i))
+		prefix = "input"
+		input := this.Input[i]
+		keys := make([]string, 0, len(input))
+		for key := range input {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			bb.SetMem(key, input[key])
+		}
+		if this.Before != nil {
+			this.Before(i, bb)
+		}
+		prefix = "set"
+		status := tick(tree, this.Target, bb)
+		listener.lines = append(listener.lines, "  => "+status.String())
+	}
+	return strings.Join(listener.lines, "\n") + "\n"
+}
+
+/**
+ * AssertGolden compares got with the content of the golden file path, and
+ * fails the test at the first different line. With `go test -b3test.update` the
+ * file is written instead.
+**/
+func AssertGolden(t testing.TB, path string, got string) bool {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v (run go test -b3test.update to create it)", err)
+		return false
+	}
+	want := string(data)
+	if got == want {
+		return true
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	line := 0
+	for line < len(gotLines) && line < len(wantLines) && gotLines[line] == wantLines[line] {
+		line++
+	}
+	at := func(lines []string) string {
+		if line < len(lines) {
+			return lines[line]
+		}
+		return "(end)"
+	}
+	t.Errorf("%s:%d differs (run go test -b3test.update to accept):\n  golden: %s\n  got:    %s",
+		path, line+1, at(wantLines), at(gotLines))
+	return false
+}
+
+// AssertGoldenTrace runs the scenario on tree and compares its trace with
+// the golden file path.
+func AssertGoldenTrace(t testing.TB, tree *BehaviorTree, scenario *Scenario, path string) bool {
+	t.Helper()
+	return AssertGolden(t, path, scenario.Trace(tree))
+}
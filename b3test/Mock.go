@@ -0,0 +1,201 @@
+/*
+Package b3test helps writing tests of behavior trees as ordinary Go tests.
+
+Leaf nodes are replaced by mocks returning scripted statuses, the time is
+controlled by a FakeClock, and assertions check the statuses of the ticks
+and the nodes executed:
+
+	tree := b3test.Load(cfg, nil)   // unknown actions and conditions are mocks
+	b3test.FindMock(tree, "see enemy").Script(b3.FAILURE, b3.SUCCESS)
+
+	board := core.NewBlackboard()
+	b3test.AssertStatusSequence(t, tree, nil, board, []b3.Status{b3.SUCCESS, b3.RUNNING})
+	b3test.AssertVisited(t, tree, nil, board, "root", "see enemy", "attack")
+
+A mock returns the statuses of its script on its successive ticks, for
+each agent (blackboard), and repeats the last one. The script can also be
+given in the node properties, for trees written by hand:
+
+	{"id": "2", "name": "MockCondition", "properties": {"script": "FAILURE SUCCESS"}}
+
+Golden tests run a `Scenario` and compare the trace of every node executed
+with a file checked in testdata; `go test -b3test.update` rewrites the files:
+
+	b3test.AssertGoldenTrace(t, tree, &b3test.Scenario{Ticks: 10}, "testdata/guard.golden")
+*/
+package b3test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+)
+
+// Mock is the scripted behavior of a mock node.
+type Mock struct {
+	mu     sync.Mutex
+	script []b3.Status
+	ticks  map[*Blackboard]int
+	total  int
+}
+
+func (this *Mock) initMock(setting *BTNodeCfg) {
+	this.ticks = make(map[*Blackboard]int)
+	if text, ok := setting.Properties["script"].(string); ok {
+		script, err := ParseScript(text)
+		if err != nil {
+			panic("b3test: node " + setting.Id + ": " + err.Error())
+		}
+		this.script = script
+	}
+}
+
+// Script sets the statuses returned by the successive ticks, the last one
+// repeats; an empty script returns SUCCESS. It resets the tick counts.
+func (this *Mock) Script(statuses ...b3.Status) *Mock {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.script = append([]b3.Status(nil), statuses...)
+	this.ticks = make(map[*Blackboard]int)
+	this.total = 0
+	return this
+}
+
+// Ticks returns the number of ticks of the node, for all the agents.
+func (this *Mock) Ticks() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.total
+}
+
+func (this *Mock) next(tick *Tick) b3.Status {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	bb := tick.Blackboard.Base()
+	i := this.ticks[bb]
+	this.ticks[bb] = i + 1
+	this.total++
+	if len(this.script) == 0 {
+		return b3.SUCCESS
+	}
+	if i >= len(this.script) {
+		i = len(this.script) - 1
+	}
+	return this.script[i]
+}
+
+func (this *Mock) mock() *Mock {
+	return this
+}
+
+// MockAction is an action returning scripted statuses.
+type MockAction struct {
+	Action
+	Mock
+}
+
+func (this *MockAction) Initialize(setting *BTNodeCfg) {
+	this.Action.Initialize(setting)
+	this.initMock(setting)
+}
+
+func (this *MockAction) OnTick(tick *Tick) b3.Status {
+	return this.next(tick)
+}
+
+// MockCondition is a condition returning scripted statuses.
+type MockCondition struct {
+	Condition
+	Mock
+}
+
+func (this *MockCondition) Initialize(setting *BTNodeCfg) {
+	this.Condition.Initialize(setting)
+	this.initMock(setting)
+}
+
+func (this *MockCondition) OnTick(tick *Tick) b3.Status {
+	return this.next(tick)
+}
+
+// ParseScript parses statuses separated by spaces or commas, for example
+// "FAILURE, SUCCESS RUNNING".
+func ParseScript(text string) ([]b3.Status, error) {
+	var script []b3.Status
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, field := range fields {
+		switch strings.ToUpper(field) {
+		case "SUCCESS":
+			script = append(script, b3.SUCCESS)
+		case "FAILURE":
+			script = append(script, b3.FAILURE)
+		case "RUNNING":
+			script = append(script, b3.RUNNING)
+		case "ERROR":
+			script = append(script, b3.ERROR)
+		default:
+			return nil, fmt.Errorf("unknown status %q", field)
+		}
+	}
+	return script, nil
+}
+
+/**
+ * Maps returns the custom nodes to load cfg with: the nodes of maps, and
+ * mocks for the leaves that are neither in maps nor built-in nodes, a
+ * MockCondition for the category "condition" and a MockAction otherwise.
+ * `MockAction` and `MockCondition` are always registered.
+**/
+func Maps(cfg *BTTreeCfg, maps *b3.RegisterStructMaps) *b3.RegisterStructMaps {
+	ext := b3.NewRegisterStructMaps()
+	ext.Register("MockAction", new(MockAction))
+	ext.Register("MockCondition", new(MockCondition))
+	for _, node := range cfg.Nodes {
+		if node.Category == "tree" || ext.CheckElem(node.Name) {
+			continue
+		}
+		if maps != nil && maps.CheckElem(node.Name) {
+			custom, _ := maps.New(node.Name)
+			ext.Register(node.Name, custom)
+			continue
+		}
+		if IsBaseNode(node.Name) || len(node.Children) > 0 || node.Child != "" {
+			continue
+		}
+		if node.Category == b3.CONDITION {
+			ext.Register(node.Name, new(MockCondition))
+		} else {
+			ext.Register(node.Name, new(MockAction))
+		}
+	}
+	return ext
+}
+
+// Load creates a tree from cfg with the nodes of Maps.
+func Load(cfg *BTTreeCfg, maps *b3.RegisterStructMaps) *BehaviorTree {
+	return CreateBevTreeFromConfig(cfg, Maps(cfg, maps))
+}
+
+/**
+ * FindMock returns the mock node with a title, or a node key (see
+ * `core.Tick.NodeKey`), searching the subtrees too. It panics if there is
+ * no such mock, to fail the test early.
+**/
+func FindMock(tree *BehaviorTree, name string) *Mock {
+	var found *Mock
+	tree.WalkKeys(func(node IBaseNode, key string, depth int) bool {
+		if m, ok := node.(interface{ mock() *Mock }); ok && found == nil && (node.GetTitle() == name || key == name) {
+			found = m.mock()
+		}
+		return found == nil
+	})
+	if found == nil {
+		panic("b3test: no mock node " + name)
+	}
+	return found
+}
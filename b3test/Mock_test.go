@@ -0,0 +1,118 @@
+package b3test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+)
+
+// 测试程序自己的-update不和b3test的flag冲突
+var _ = flag.Bool("update", false, "flag of the test binary")
+
+func guardTree() *BTTreeCfg {
+	return &BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "5"}},
+			"2": {Id: "2", Name: "Sequence", Title: "fight", Children: []string{"3", "4"}},
+			"3": {Id: "3", Name: "SeeEnemy", Category: "condition", Title: "see enemy"},
+			"4": {Id: "4", Name: "Attack", Category: "action", Title: "attack"},
+			"5": {Id: "5", Name: "Patrol", Category: "action", Title: "patrol"},
+		},
+	}
+}
+
+func TestScriptedTree(t *testing.T) {
+	const S, F, R = b3.SUCCESS, b3.FAILURE, b3.RUNNING
+	tests := []struct {
+		name              string
+		see, attack, walk []b3.Status
+		want              []b3.Status
+	}{
+		{"no enemy", []b3.Status{F}, nil, []b3.Status{R}, []b3.Status{R, R, R}},
+		{"enemy appears", []b3.Status{F, S}, []b3.Status{R, S}, []b3.Status{R}, []b3.Status{R, R, S}},
+		{"patrol ends", []b3.Status{F}, nil, []b3.Status{R, F}, []b3.Status{R, F, F}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := Load(guardTree(), nil)
+			FindMock(tree, "see enemy").Script(test.see...)
+			FindMock(tree, "4").Script(test.attack...)
+			FindMock(tree, "patrol").Script(test.walk...)
+			AssertStatusSequence(t, tree, nil, NewBlackboard(), test.want)
+		})
+	}
+}
+
+func TestVisited(t *testing.T) {
+	tree := Load(guardTree(), nil)
+	see := FindMock(tree, "see enemy").Script(b3.FAILURE, b3.SUCCESS)
+	board := NewBlackboard()
+	AssertVisited(t, tree, nil, board, "root", "fight", "see enemy", "patrol")
+	AssertVisited(t, tree, nil, board, "1", "2", "3", "attack")
+	if see.Ticks() != 2 {
+		t.Errorf("see enemy ticked %d times, want 2", see.Ticks())
+	}
+
+	// the mocks of another agent start their script again
+	if _, visits := Visited(tree, nil, NewBlackboard()); len(visits) != 4 || visits[3].Title != "patrol" {
+		t.Errorf("other agent visited %v", visits)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	tree := Load(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Wait", Title: "wait", Properties: map[string]interface{}{"milliseconds": 250.0}},
+			"3": {Id: "3", Name: "MockAction", Title: "act", Properties: map[string]interface{}{"script": "FAILURE, SUCCESS"}},
+		},
+	}, nil)
+	clock := NewFakeClock(time.Time{})
+	clock.Step = 100 * time.Millisecond
+	tree.SetClock(clock)
+
+	// wait opens at 0 and ends after 250ms
+	AssertStatusSequence(t, tree, nil, NewBlackboard(), []b3.Status{b3.RUNNING, b3.RUNNING, b3.RUNNING, b3.FAILURE})
+	if got := clock.Now().Sub(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); got != 400*time.Millisecond {
+		t.Errorf("clock advanced %v, want 400ms", got)
+	}
+}
+
+// Attack 写入黑板的节点
+type Attack struct {
+	Action
+}
+
+func (this *Attack) OnTick(tick *Tick) b3.Status {
+	hits := tick.Blackboard.GetInt("hits", "", "") + 1
+	tick.Blackboard.SetMem("hits", hits)
+	if hits < 2 {
+		return b3.RUNNING
+	}
+	return b3.SUCCESS
+}
+
+func TestGoldenTrace(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("Attack", new(Attack))
+	tree := Load(guardTree(), maps)
+	FindMock(tree, "patrol").Script(b3.RUNNING)
+
+	scenario := &Scenario{
+		Ticks: 4,
+		Input: map[int]map[string]interface{}{1: {"enemy": "orc"}},
+		Before: func(tick int, bb *Blackboard) {
+			if tick == 1 {
+				FindMock(tree, "see enemy").Script(b3.SUCCESS)
+			}
+		},
+	}
+	FindMock(tree, "see enemy").Script(b3.FAILURE)
+	AssertGoldenTrace(t, tree, scenario, "testdata/guard.golden")
+}
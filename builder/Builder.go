@@ -0,0 +1,271 @@
+/*
+Package builder writes behavior trees in Go code instead of the editor, for
+procedural AI, tests and tools:
+
+	tree := builder.NewTree("guard",
+		builder.Priority("root",
+			builder.Sequence("fight",
+				builder.Condition("SeeEnemy", nil),
+				builder.Action("Attack", builder.Props{"damage": 3}),
+			),
+			builder.Inverter(builder.Condition("IsTired", nil)),
+			builder.Action("Patrol", nil).Title("patrol"),
+		))
+	bt, err := tree.Build(maps)   // *core.BehaviorTree
+	cfg := tree.Config()          // config.BTTreeCfg, as exported by the editor
+
+The nodes get IDs "1", "2"... in depth-first order, so the config of a tree
+is stable. A node used twice is copied: each use gets its own ID, and its
+own state when the tree runs. Numbers of the properties are stored as
+float64, as in the JSON of the editor.
+*/
+package builder
+
+import (
+	"fmt"
+	"strconv"
+
+	b3 "behavior3go"
+	"behavior3go/config"
+	"behavior3go/core"
+	"behavior3go/loader"
+)
+
+// Props are the properties of a node.
+type Props map[string]interface{}
+
+// Node is a node being built. The functions creating nodes return a new
+// Node, the methods change it and return it for chaining.
+type Node struct {
+	name        string
+	category    string
+	title       string
+	description string
+	properties  Props
+	children    []*Node
+}
+
+// New returns a node of any category: b3.ACTION, b3.CONDITION,
+// b3.COMPOSITE, b3.DECORATOR or "tree". Decorators take one child: Build
+// fails if a decorator has more.
+func New(name, category string, props Props, children ...*Node) *Node {
+	return &Node{name: name, category: category, title: name, properties: props, children: children}
+}
+
+// Action returns an action node, titled by its name.
+func Action(name string, props Props) *Node {
+	return New(name, b3.ACTION, props)
+}
+
+// Condition returns a condition node, titled by its name.
+func Condition(name string, props Props) *Node {
+	return New(name, b3.CONDITION, props)
+}
+
+// Composite returns a composite node registered as name.
+func Composite(name, title string, children ...*Node) *Node {
+	return New(name, b3.COMPOSITE, nil, children...).Title(title)
+}
+
+// Decorator returns a decorator node registered as name. The child can be
+// nil.
+func Decorator(name string, props Props, child *Node) *Node {
+	node := New(name, b3.DECORATOR, props)
+	if child != nil {
+		node.children = []*Node{child}
+	}
+	return node
+}
+
+// SubTree returns a `tree` node calling the tree treeID, with the port
+// mapping of the call (see core.SubTree), nil for none.
+func SubTree(treeID string, ports Props) *Node {
+	return New(treeID, "tree", ports)
+}
+
+// Title sets the title of the node.
+func (this *Node) Title(title string) *Node {
+	this.title = title
+	return this
+}
+
+// Description sets the description of the node.
+func (this *Node) Description(description string) *Node {
+	this.description = description
+	return this
+}
+
+// Prop sets a property of the node.
+func (this *Node) Prop(name string, value interface{}) *Node {
+	if this.properties == nil {
+		this.properties = Props{}
+	}
+	this.properties[name] = value
+	return this
+}
+
+// Tree is a tree being built.
+type Tree struct {
+	// ID of the tree, used by the `tree` nodes calling it. A UUID is
+	// generated by the first Config if empty.
+	ID          string
+	Title       string
+	Description string
+	Properties  Props
+	Root        *Node
+}
+
+// NewTree returns a tree with the root node.
+func NewTree(title string, root *Node) *Tree {
+	return &Tree{Title: title, Root: root}
+}
+
+/**
+ * Config returns the tree as the editor exports it. The nodes get IDs in
+ * depth-first order from "1"; the category of the nodes built with `New`
+ * is kept as given. A node that is its own descendant is listed as a
+ * cycle, which Build rejects.
+**/
+func (this *Tree) Config() *config.BTTreeCfg {
+	if this.ID == "" {
+		this.ID = b3.CreateUUID()
+	}
+	cfg := &config.BTTreeCfg{
+		ID:          this.ID,
+		Title:       this.Title,
+		Description: this.Description,
+		Properties:  normalize(this.Properties),
+		Nodes:       make(map[string]config.BTNodeCfg),
+	}
+	if cfg.Properties == nil {
+		cfg.Properties = map[string]interface{}{}
+	}
+	if this.Root != nil {
+		c := &configWriter{cfg: cfg, path: make(map[*Node]string)}
+		cfg.Root = c.addNode(this.Root)
+	}
+	return cfg
+}
+
+// configWriter 生成树的节点配置
+type configWriter struct {
+	cfg  *config.BTTreeCfg
+	last int
+	// 从根到当前节点的路径上的节点和它们的ID
+	path map[*Node]string
+}
+
+// addNode 深度优先加入节点和它的子节点，返回节点ID。重复使用的节点每次得到
+// 新的ID；节点是自己的后代时使用祖先的ID，加载时报告循环
+func (this *configWriter) addNode(node *Node) string {
+	if id, ok := this.path[node]; ok {
+		return id
+	}
+	this.last++
+	id := strconv.Itoa(this.last)
+	this.path[node] = id
+	defer delete(this.path, node)
+	nodeCfg := config.BTNodeCfg{
+		Id:          id,
+		Name:        node.name,
+		Category:    node.category,
+		Title:       node.title,
+		Description: node.description,
+		Properties:  normalize(node.properties),
+	}
+	if nodeCfg.Properties == nil {
+		nodeCfg.Properties = map[string]interface{}{}
+	}
+	for _, child := range node.children {
+		if child == nil {
+			continue
+		}
+		cid := this.addNode(child)
+		if node.category == b3.DECORATOR {
+			nodeCfg.Child = cid
+		} else {
+			nodeCfg.Children = append(nodeCfg.Children, cid)
+		}
+	}
+	this.cfg.Nodes[id] = nodeCfg
+	return id
+}
+
+// normalize 复制属性，数字转为float64，和编辑器json解析的结果一致
+func normalize(props Props) map[string]interface{} {
+	if props == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		switch n := v.(type) {
+		case int:
+			v = float64(n)
+		case int8:
+			v = float64(n)
+		case int16:
+			v = float64(n)
+		case int32:
+			v = float64(n)
+		case int64:
+			v = float64(n)
+		case uint:
+			v = float64(n)
+		case uint8:
+			v = float64(n)
+		case uint16:
+			v = float64(n)
+		case uint32:
+			v = float64(n)
+		case uint64:
+			v = float64(n)
+		case float32:
+			v = float64(n)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Build loads the tree, with the built-in nodes and the custom nodes of
+// extMaps (can be nil).
+func (this *Tree) Build(extMaps *b3.RegisterStructMaps) (*core.BehaviorTree, error) {
+	if err := checkNode(this.Root, make(map[*Node]bool)); err != nil {
+		return nil, err
+	}
+	return loader.TryCreateBevTreeFromConfig(this.Config(), extMaps)
+}
+
+// checkNode 检查装饰节点最多只有一个子节点
+func checkNode(node *Node, checked map[*Node]bool) error {
+	if node == nil || checked[node] {
+		return nil
+	}
+	checked[node] = true
+	if node.category == b3.DECORATOR {
+		count := 0
+		for _, child := range node.children {
+			if child != nil {
+				count++
+			}
+		}
+		if count > 1 {
+			return fmt.Errorf("builder: decorator %s(%s) has %d children, want one", node.title, node.name, count)
+		}
+	}
+	for _, child := range node.children {
+		if err := checkNode(child, checked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustBuild works as Build and panics on error.
+func (this *Tree) MustBuild(extMaps *b3.RegisterStructMaps) *core.BehaviorTree {
+	tree, err := this.Build(extMaps)
+	if err != nil {
+		panic(err)
+	}
+	return tree
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"testing"
+
+	b3 "behavior3go"
+	"behavior3go/config"
+	"behavior3go/core"
+)
+
+// Hit 读取属性damage的自定义节点
+type Hit struct {
+	core.Action
+	damage int
+}
+
+func (this *Hit) Initialize(setting *config.BTNodeCfg) {
+	this.Action.Initialize(setting)
+	this.damage = setting.GetPropertyAsInt("damage")
+}
+
+func (this *Hit) OnTick(tick *core.Tick) b3.Status {
+	hp := tick.Blackboard.GetInt("hp", "", "") - this.damage
+	tick.Blackboard.SetMem("hp", hp)
+	return b3.SUCCESS
+}
+
+func TestConfig(t *testing.T) {
+	done := Failer().Title("done")
+	tree := NewTree("fight", Priority("root",
+		Sequence("attack",
+			Inverter(done),
+			Action("Hit", Props{"damage": 4}),
+		),
+		done,
+		SubTree("flee", Props{"target": "$enemy"}),
+	))
+	cfg := tree.Config()
+	if cfg.ID == "" || cfg.ID != tree.ID || cfg.Title != "fight" || cfg.Root != "1" {
+		t.Fatalf("tree = %+v", cfg)
+	}
+	// 深度优先编号，重复使用的节点每次得到新的ID
+	want := map[string]string{"1": "root", "2": "attack", "3": "Inverter", "4": "done", "5": "Hit", "6": "done", "7": "flee"}
+	if len(cfg.Nodes) != len(want) {
+		t.Fatalf("nodes = %+v", cfg.Nodes)
+	}
+	for id, title := range want {
+		if cfg.Nodes[id].Id != id || cfg.Nodes[id].Title != title {
+			t.Errorf("node %s = %+v, want %s", id, cfg.Nodes[id], title)
+		}
+	}
+	if root := cfg.Nodes["1"]; root.Category != b3.COMPOSITE || len(root.Children) != 3 || root.Children[1] != "6" {
+		t.Errorf("root = %+v", root)
+	}
+	if inverter := cfg.Nodes["3"]; inverter.Child != "4" || inverter.Children != nil {
+		t.Errorf("inverter = %+v", inverter)
+	}
+	if hit := cfg.Nodes["5"]; hit.Properties["damage"] != 4.0 || hit.Category != b3.ACTION {
+		t.Errorf("hit = %+v", hit)
+	}
+	if flee := cfg.Nodes["7"]; flee.Category != "tree" || flee.Name != "flee" {
+		t.Errorf("flee = %+v", flee)
+	}
+	if again := tree.Config(); again.ID != cfg.ID {
+		t.Errorf("tree ID changed: %s, %s", cfg.ID, again.ID)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("Hit", new(Hit))
+	tree := NewTree("fight", MemSequence("root",
+		Repeater(2, Action("Hit", Props{"damage": 3})),
+		Succeeder(),
+	)).MustBuild(maps)
+
+	board := core.NewBlackboard()
+	board.SetMem("hp", 10)
+	// 每次tick重复两次
+	if status := tree.Tick(nil, board); status != b3.SUCCESS || board.GetInt("hp", "", "") != 4 {
+		t.Errorf("tick = %v, hp = %d", status, board.GetInt("hp", "", ""))
+	}
+
+	if _, err := NewTree("bad", Action("Nope", nil)).Build(nil); err == nil {
+		t.Error("unknown node built")
+	}
+	if _, err := NewTree("bad", New("Inverter", b3.DECORATOR, nil, Succeeder(), Failer())).Build(nil); err == nil {
+		t.Error("decorator with two children built")
+	}
+	loop := Sequence("loop")
+	loop.children = append(loop.children, Inverter(loop))
+	if _, err := NewTree("bad", loop).Build(nil); err == nil {
+		t.Error("cycle built")
+	}
+}
+
+func TestReusedNode(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("Hit", new(Hit))
+	// 同一个节点用在两个位置，各自是一个节点
+	hit := Action("Hit", Props{"damage": 1})
+	tree := NewTree("twice", MemSequence("root", hit, Runner(), hit))
+	cfg := tree.Config()
+	if root := cfg.Nodes["1"]; len(cfg.Nodes) != 4 || fmt.Sprint(root.Children) != "[2 3 4]" {
+		t.Fatalf("nodes = %+v", cfg.Nodes)
+	}
+
+	bt := tree.MustBuild(maps)
+	var nodes []core.IBaseNode
+	bt.Walk(func(node core.IBaseNode, depth int) bool {
+		nodes = append(nodes, node)
+		return true
+	})
+	if len(nodes) != 4 || nodes[1] == nodes[3] {
+		t.Errorf("nodes = %v", nodes)
+	}
+}
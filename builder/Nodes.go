@@ -0,0 +1,84 @@
+package builder
+
+// 内置节点，和 loader 注册的名字一致
+
+// Sequence ticks its children in order until one does not succeed.
+func Sequence(title string, children ...*Node) *Node {
+	return Composite("Sequence", title, children...)
+}
+
+// Priority ticks its children in order until one does not fail.
+func Priority(title string, children ...*Node) *Node {
+	return Composite("Priority", title, children...)
+}
+
+// MemSequence is a Sequence resuming from its RUNNING child.
+func MemSequence(title string, children ...*Node) *Node {
+	return Composite("MemSequence", title, children...)
+}
+
+// MemPriority is a Priority resuming from its RUNNING child.
+func MemPriority(title string, children ...*Node) *Node {
+	return Composite("MemPriority", title, children...)
+}
+
+// Inverter swaps the SUCCESS and FAILURE of its child.
+func Inverter(child *Node) *Node {
+	return Decorator("Inverter", nil, child)
+}
+
+// Limiter ticks its child until it has ended maxLoop times.
+func Limiter(maxLoop int, child *Node) *Node {
+	return Decorator("Limiter", Props{"maxLoop": maxLoop}, child)
+}
+
+// MaxTime fails when its child runs longer than maxTime milliseconds.
+func MaxTime(maxTime int64, child *Node) *Node {
+	return Decorator("MaxTime", Props{"maxTime": maxTime}, child)
+}
+
+// Repeater ticks its child maxLoop times.
+func Repeater(maxLoop int, child *Node) *Node {
+	return Decorator("Repeater", Props{"maxLoop": maxLoop}, child)
+}
+
+// RepeatUntilFailure ticks its child until it fails, at most maxLoop times.
+func RepeatUntilFailure(maxLoop int, child *Node) *Node {
+	return Decorator("RepeatUntilFailure", Props{"maxLoop": maxLoop}, child)
+}
+
+// RepeatUntilSuccess ticks its child until it succeeds, at most maxLoop
+// times.
+func RepeatUntilSuccess(maxLoop int, child *Node) *Node {
+	return Decorator("RepeatUntilSuccess", Props{"maxLoop": maxLoop}, child)
+}
+
+// Succeeder returns SUCCESS.
+func Succeeder() *Node {
+	return Action("Succeeder", nil)
+}
+
+// Failer returns FAILURE.
+func Failer() *Node {
+	return Action("Failer", nil)
+}
+
+// Runner returns RUNNING.
+func Runner() *Node {
+	return Action("Runner", nil)
+}
+
+// Error returns ERROR.
+func Error() *Node {
+	return Action("Error", nil)
+}
+
+// Wait returns RUNNING for milliseconds, then SUCCESS.
+func Wait(milliseconds int64) *Node {
+	return Action("Wait", Props{"milliseconds": milliseconds})
+}
+
+// Log prints info and returns SUCCESS.
+func Log(info string) *Node {
+	return Action("Log", Props{"info": info})
+}
@@ -0,0 +1,92 @@
+package chrometrace
+
+import (
+	"io"
+	"strconv"
+
+	"behavior3go/record"
+)
+
+/**
+ * WriteRecording writes a recording of the record package as a trace. Each
+ * frame is a span starting at the time of the tree clock, and each node
+ * event takes one microsecond: the nesting of the spans is exact, their
+ * durations are not.
+**/
+func WriteRecording(w io.Writer, rec *record.Recording) error {
+	out := newWriter(w)
+	name := rec.Title
+	if name == "" {
+		name = rec.Tree
+	}
+	out.write(&event{Name: "process_name", Ph: "M", Pid: 1, Args: map[string]interface{}{"name": name}})
+	out.write(&event{Name: "thread_name", Ph: "M", Pid: 1, Tid: 1, Args: map[string]interface{}{"name": "agent"}})
+
+	var ts float64
+	emit := func(e *event) {
+		e.Pid, e.Tid, e.Ts = 1, 1, ts
+		out.write(e)
+		ts++
+	}
+	nodeArgs := func(key string) (string, map[string]interface{}) {
+		node := rec.Node(key)
+		title := node.Title
+		if title == "" {
+			title = node.Name
+		}
+		if title == "" {
+			title = key
+		}
+		return title, map[string]interface{}{"key": key, "name": node.Name}
+	}
+
+	for i := range rec.Frames {
+		frame := &rec.Frames[i]
+		// 按树时钟放置帧，但不早于上一帧的事件
+		if at := float64(frame.Time-rec.Frames[0].Time) / 1000; at > ts {
+			ts = at
+		}
+		span := "frame " + strconv.Itoa(i)
+		if frame.Halt {
+			span += " halt"
+		}
+		emit(&event{Name: span, Cat: "tick", Ph: "B"})
+		for j := range frame.Input {
+			emit(&event{Name: "input", Cat: "write", Ph: "i", Scope: "t",
+				Args: map[string]interface{}{"write": frame.Input[j].String()}})
+		}
+
+		var ticked *record.Event
+		for j := range frame.Events {
+			e := &frame.Events[j]
+			switch e.Kind {
+			case record.EnterEvent:
+				title, args := nodeArgs(e.Node)
+				emit(&event{Name: title, Cat: "node", Ph: "B", Args: args})
+			case record.TickedEvent:
+				ticked = e
+			case record.ExitEvent:
+				title, _ := nodeArgs(e.Node)
+				end := &event{Name: title, Cat: "node", Ph: "E"}
+				if ticked != nil && ticked.Node == e.Node {
+					end.Args = map[string]interface{}{"status": ticked.Status.String()}
+				}
+				emit(end)
+			case record.HaltEvent:
+				title, args := nodeArgs(e.Node)
+				emit(&event{Name: "halt " + title, Cat: "halt", Ph: "i", Scope: "t", Args: args})
+			case record.WriteEvent:
+				_, args := nodeArgs(e.Node)
+				args["write"] = e.Write.String()
+				emit(&event{Name: "write", Cat: "write", Ph: "i", Scope: "t", Args: args})
+			}
+		}
+
+		var args map[string]interface{}
+		if !frame.Halt {
+			args = map[string]interface{}{"status": frame.Status.String()}
+		}
+		emit(&event{Name: span, Cat: "tick", Ph: "E", Args: args})
+	}
+	return out.close()
+}
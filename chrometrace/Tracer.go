@@ -0,0 +1,284 @@
+/*
+Package chrometrace exports the execution of behavior trees in the Chrome
+trace-event JSON format, to open in chrome://tracing or Perfetto.
+
+	tracer, _ := chrometrace.Create("ai.json")
+	tracer.Attach(tree)
+	tracer.SetAgentName(npc1.board, "npc1")
+	... // game loop
+	tracer.Close()
+
+Every tick is a span, and every node execution a span nested in the span of
+its parent; the nodes of a subtree are nested in the `tree` node calling
+it. Each tree is a process of the trace and each agent (blackboard) a
+thread. Halted nodes are instant events.
+
+`WriteRecording` converts a recording of the record package, whose node
+events have no duration: the spans are laid out in event order.
+*/
+package chrometrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// event 一个trace事件，见Trace Event Format
+type event struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// writer 流式写入traceEvents数组
+type writer struct {
+	out   *bufio.Writer
+	count int
+	err   error
+}
+
+func newWriter(w io.Writer) *writer {
+	out := bufio.NewWriter(w)
+	_, err := out.WriteString("{\"displayTimeUnit\":\"ms\",\"traceEvents\":[\n")
+	return &writer{out: out, err: err}
+}
+
+func (this *writer) write(e *event) {
+	if this.err != nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		this.err = err
+		return
+	}
+	if this.count > 0 {
+		this.out.WriteString(",\n")
+	}
+	this.count++
+	_, this.err = this.out.Write(data)
+}
+
+func (this *writer) close() error {
+	if this.err != nil {
+		return this.err
+	}
+	this.out.WriteString("\n]}\n")
+	return this.out.Flush()
+}
+
+func nodeName(node IBaseNode) string {
+	if node.GetTitle() != "" {
+		return node.GetTitle()
+	}
+	return node.GetName()
+}
+
+func nodeCategory(node IBaseNode) string {
+	if _, ok := node.(*SubTree); ok {
+		return "tree"
+	}
+	return node.GetCategory()
+}
+
+// Tracer writes the ticks of the attached trees as trace events. It is safe
+// for concurrent use.
+type Tracer struct {
+	mu     sync.Mutex
+	out    *writer
+	closer io.Closer
+	start  time.Time
+	// Close之后还在进行的tick不再写入
+	closed bool
+
+	trees   TreeListeners[*treeTracer]
+	pids    int
+	threads map[*Blackboard]int
+	names   map[*Blackboard]string
+	named   map[[2]int]bool
+}
+
+// treeTracer 一棵树的listener
+type treeTracer struct {
+	BaseTickListener
+	tracer *Tracer
+	pid    int
+	// 每个tick最后返回的节点状态，在节点退出时写入
+	ticked map[*Tick]tickedNode
+}
+
+type tickedNode struct {
+	node   IBaseNode
+	status b3.Status
+}
+
+// New creates a tracer writing to w. Close must be called to end the JSON
+// document; it does not close w.
+func New(w io.Writer) *Tracer {
+	return &Tracer{
+		out:     newWriter(w),
+		start:   time.Now(),
+		threads: make(map[*Blackboard]int),
+		names:   make(map[*Blackboard]string),
+		named:   make(map[[2]int]bool),
+	}
+}
+
+// Create creates a tracer writing to a file, closed by Close.
+func Create(path string) (*Tracer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	tracer := New(file)
+	tracer.closer = file
+	return tracer, nil
+}
+
+// Attach starts tracing the ticks of tree.
+func (this *Tracer) Attach(tree *BehaviorTree) {
+	this.trees.Attach(tree, func() *treeTracer {
+		this.mu.Lock()
+		defer this.mu.Unlock()
+		this.pids++
+		t := &treeTracer{tracer: this, pid: this.pids, ticked: make(map[*Tick]tickedNode)}
+		if !this.closed {
+			this.out.write(&event{Name: "process_name", Ph: "M", Pid: t.pid, Args: map[string]interface{}{"name": tree.GetDisplayName()}})
+		}
+		return t
+	})
+	// 与Close同时调用时，Close之后加入的listener在这里移除
+	this.mu.Lock()
+	closed := this.closed
+	this.mu.Unlock()
+	if closed {
+		this.trees.Detach(tree)
+	}
+}
+
+// Detach stops tracing tree.
+func (this *Tracer) Detach(tree *BehaviorTree) {
+	this.trees.Detach(tree)
+}
+
+// SetAgentName names the thread of the agent owning blackboard, the
+// default is "agent N".
+func (this *Tracer) SetAgentName(blackboard *Blackboard, name string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.names[blackboard] = name
+}
+
+// Close detaches the trees and ends the trace. The ticks running during
+// Close are not traced any more.
+func (this *Tracer) Close() error {
+	this.mu.Lock()
+	if this.closed {
+		this.mu.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.mu.Unlock()
+	this.trees.DetachAll()
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	err := this.out.close()
+	if this.closer != nil {
+		if cerr := this.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// _emit 写入一个事件，调用时不持有锁
+func (this *treeTracer) _emit(tick *Tick, e *event) {
+	now := time.Now()
+	tracer := this.tracer
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if tracer.closed {
+		return
+	}
+
+	bb := tick.Blackboard.Base()
+	tid, ok := tracer.threads[bb]
+	if !ok {
+		tid = len(tracer.threads) + 1
+		tracer.threads[bb] = tid
+	}
+	// 线程在每个进程中第一次出现时写入名字
+	if thread := [2]int{this.pid, tid}; !tracer.named[thread] {
+		tracer.named[thread] = true
+		name, ok := tracer.names[bb]
+		if !ok {
+			name = "agent " + strconv.Itoa(tid)
+		}
+		tracer.out.write(&event{Name: "thread_name", Ph: "M", Pid: this.pid, Tid: tid, Args: map[string]interface{}{"name": name}})
+	}
+	e.Pid = this.pid
+	e.Tid = tid
+	e.Ts = float64(now.Sub(tracer.start).Nanoseconds()) / 1000
+	tracer.out.write(e)
+}
+
+func (this *treeTracer) TickStart(tick *Tick) {
+	this._emit(tick, &event{Name: "tick", Cat: "tick", Ph: "B"})
+}
+
+func (this *treeTracer) TickEnd(tick *Tick, status b3.Status) {
+	this.tracer.mu.Lock()
+	delete(this.ticked, tick)
+	this.tracer.mu.Unlock()
+
+	args := map[string]interface{}{"status": status.String()}
+	if errs := tick.Errors(); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		args["errors"] = messages
+	}
+	this._emit(tick, &event{Name: "tick", Cat: "tick", Ph: "E", Args: args})
+}
+
+func (this *treeTracer) EnterNode(tick *Tick, node IBaseNode) {
+	this._emit(tick, &event{Name: nodeName(node), Cat: nodeCategory(node), Ph: "B",
+		Args: map[string]interface{}{"id": node.GetID(), "key": tick.NodeKey(node), "name": node.GetName()}})
+}
+
+func (this *treeTracer) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	this.tracer.mu.Lock()
+	this.ticked[tick] = tickedNode{node, status}
+	this.tracer.mu.Unlock()
+}
+
+func (this *treeTracer) HaltNode(tick *Tick, node IBaseNode) {
+	this._emit(tick, &event{Name: "halt " + nodeName(node), Cat: "halt", Ph: "i", Scope: "t",
+		Args: map[string]interface{}{"id": node.GetID(), "key": tick.NodeKey(node)}})
+}
+
+func (this *treeTracer) ExitNode(tick *Tick, node IBaseNode) {
+	e := &event{Name: nodeName(node), Cat: nodeCategory(node), Ph: "E"}
+	this.tracer.mu.Lock()
+	// panic展开的节点没有状态
+	if last := this.ticked[tick]; last.node == node {
+		e.Args = map[string]interface{}{"status": last.status.String()}
+	}
+	this.tracer.mu.Unlock()
+	this._emit(tick, e)
+}
@@ -0,0 +1,163 @@
+package chrometrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+	"behavior3go/record"
+)
+
+type traceFile struct {
+	TraceEvents []event `json:"traceEvents"`
+}
+
+// checkSpans 检查B/E事件成对嵌套，返回节点span的名字
+func checkSpans(t *testing.T, data []byte) []string {
+	var trace traceFile
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, data)
+	}
+	var stack, names []string
+	for _, e := range trace.TraceEvents {
+		switch e.Ph {
+		case "B":
+			stack = append(stack, e.Name)
+			if e.Cat != "tick" {
+				names = append(names, e.Name)
+			}
+		case "E":
+			if len(stack) == 0 || stack[len(stack)-1] != e.Name {
+				t.Fatalf("end of %q, open spans %v", e.Name, stack)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) != 0 {
+		t.Fatalf("spans not ended: %v", stack)
+	}
+	return names
+}
+
+func TestTracer(t *testing.T) {
+	SetSubTreeLoadFunc(func(id string) *BehaviorTree {
+		return CreateBevTreeFromConfig(&BTTreeCfg{
+			ID:   id,
+			Root: "1",
+			Nodes: map[string]BTNodeCfg{
+				"1": {Id: "1", Name: "Succeeder", Title: "inner"},
+			},
+		}, b3.NewRegisterStructMaps())
+	})
+	defer SetSubTreeLoadFunc(nil)
+
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Title: "npc",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "sub", Title: "call", Category: "tree"},
+			"3": {Id: "3", Name: "Runner", Title: "wait"},
+		},
+	}, b3.NewRegisterStructMaps())
+
+	var buf bytes.Buffer
+	tracer := New(&buf)
+	tracer.Attach(tree)
+	board := NewBlackboard()
+	tracer.SetAgentName(board, "npc1")
+	tree.Tick(struct{}{}, board)
+	tree.Halt(board)
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := checkSpans(t, buf.Bytes())
+	want := []string{"root", "call", "inner", "wait"}
+	if len(names) != len(want) {
+		t.Fatalf("spans %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("spans %v, want %v", names, want)
+		}
+	}
+	for _, text := range []string{`"name":"npc1"`, `"name":"halt wait"`, `"status":"RUNNING"`, `"key":"2/1"`} {
+		if !bytes.Contains(buf.Bytes(), []byte(text)) {
+			t.Errorf("missing %s in\n%s", text, buf.Bytes())
+		}
+	}
+}
+
+func TestCloseWhileTicking(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "idle"},
+		},
+	}, b3.NewRegisterStructMaps())
+
+	var buf bytes.Buffer
+	tracer := New(&buf)
+	tracer.Attach(tree)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		board := NewBlackboard()
+		for i := 0; i < 1000; i++ {
+			tree.Tick(nil, board)
+		}
+	}()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Close之后的事件没有写在文档结尾之后
+	var trace traceFile
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("invalid trace: %v", err)
+	}
+}
+
+func TestWriteRecording(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Title: "npc",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "MemSequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "ok"},
+			"3": {Id: "3", Name: "Runner", Title: "wait"},
+		},
+	}, b3.NewRegisterStructMaps())
+	board := NewBlackboard()
+	recorder := record.NewRecorder(tree, board)
+	tree.Tick(struct{}{}, board)
+	board.SetMem("alert", true)
+	tree.Tick(struct{}{}, board)
+	tree.Halt(board)
+	recorder.Stop()
+
+	var buf bytes.Buffer
+	if err := WriteRecording(&buf, recorder.Recording()); err != nil {
+		t.Fatal(err)
+	}
+	names := checkSpans(t, buf.Bytes())
+	if len(names) != 5 {
+		t.Errorf("spans %v, want root ok wait root wait", names)
+	}
+	for _, text := range []string{`"name":"frame 2 halt"`, `"name":"halt wait"`, `"write":"alert=true"`} {
+		if !bytes.Contains(buf.Bytes(), []byte(text)) {
+			t.Errorf("missing %s in\n%s", text, buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+b3cover merges coverage profiles written by the coverage package and
+writes the coverage report of the trees.
+
+	b3cover tests.b3cover simulation.b3cover
+	b3cover -html coverage.html -o merged.b3cover *.b3cover
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"behavior3go/coverage"
+)
+
+var (
+	htmlFile = flag.String("html", "", "write the HTML report to this file instead of the text report")
+	outFile  = flag.String("o", "", "write the merged profile to this file")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: b3cover [flags] profile...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	merged := &coverage.Profile{}
+	for _, path := range flag.Args() {
+		profile, err := coverage.LoadFile(path)
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", path, err))
+		}
+		merged.Merge(profile)
+	}
+	if *outFile != "" {
+		if err := merged.SaveFile(*outFile); err != nil {
+			fail(err)
+		}
+	}
+	if *htmlFile == "" {
+		if err := merged.WriteText(os.Stdout); err != nil {
+			fail(err)
+		}
+		return
+	}
+	file, err := os.Create(*htmlFile)
+	if err != nil {
+		fail(err)
+	}
+	if err := merged.WriteHTML(file); err != nil {
+		fail(err)
+	}
+	if err := file.Close(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "b3cover:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,191 @@
+/*
+b3replay replays a recording made with the record package against the tree
+configuration, and reports where the replay diverges from the recording.
+
+	b3replay -tree tree.json npc1.b3rec
+	b3replay -project project.b3 -id <tree id> -step npc1.b3rec
+
+The leaf nodes (actions and conditions) are replaced by stubs returning the
+recorded statuses, so the game nodes are not needed. Composites and
+decorators must be built-in nodes. The exit status is 1 when the replay
+diverges.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	. "behavior3go/config"
+	"behavior3go/record"
+)
+
+var (
+	treeFile    = flag.String("tree", "", "tree exported by the editor (json)")
+	projectFile = flag.String("project", "", "project exported by the editor (json)")
+	rawFile     = flag.String("raw", "", "editor project file (.b3)")
+	treeID      = flag.String("id", "", "id of the recorded tree in the project, default: the tree with the recorded title, or the selected tree")
+	verbose     = flag.Bool("v", false, "print the events of every frame")
+	step        = flag.Bool("step", false, "wait for Enter after every frame")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: b3replay (-tree file | -project file | -raw file) [flags] recording")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rec, err := record.LoadFile(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	trees, err := loadTrees(rec)
+	if err != nil {
+		fail(err)
+	}
+	replayer, err := record.NewReplayer(rec, trees, nil)
+	if err != nil {
+		fail(err)
+	}
+	defer replayer.Close()
+
+	fmt.Printf("replaying %q: %d frames\n", rec.Title, len(rec.Frames))
+	input := bufio.NewReader(os.Stdin)
+	for !replayer.Done() {
+		result := replayer.Step()
+		printFrame(rec, result)
+		if result.Divergence != nil {
+			printDivergence(rec, result)
+			os.Exit(1)
+		}
+		if *step {
+			fmt.Print("-- Enter for the next frame --")
+			input.ReadString('\n')
+		}
+	}
+	fmt.Println("no divergence")
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "b3replay:", err)
+	os.Exit(2)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// loadTrees 读取树配置，录制的树排在第一个
+func loadTrees(rec *record.Recording) ([]*BTTreeCfg, error) {
+	var project BTProjectCfg
+	switch {
+	case *treeFile != "":
+		var tree BTTreeCfg
+		if err := readJSON(*treeFile, &tree); err != nil {
+			return nil, err
+		}
+		return []*BTTreeCfg{&tree}, nil
+	case *projectFile != "":
+		if err := readJSON(*projectFile, &project); err != nil {
+			return nil, err
+		}
+	case *rawFile != "":
+		var raw RawProjectCfg
+		if err := readJSON(*rawFile, &raw); err != nil {
+			return nil, err
+		}
+		project = raw.Data
+	default:
+		return nil, fmt.Errorf("one of -tree, -project or -raw is required")
+	}
+
+	first := -1
+	for i := range project.Trees {
+		tree := &project.Trees[i]
+		if (*treeID != "" && tree.ID == *treeID) || (*treeID == "" && first < 0 && tree.Title == rec.Title) {
+			first = i
+		}
+	}
+	if first < 0 && *treeID == "" {
+		for i := range project.Trees {
+			if project.Trees[i].ID == project.Select {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return nil, fmt.Errorf("recorded tree %q not found in the project, use -id", rec.Title)
+	}
+	trees := []*BTTreeCfg{&project.Trees[first]}
+	for i := range project.Trees {
+		if i != first {
+			trees = append(trees, &project.Trees[i])
+		}
+	}
+	return trees, nil
+}
+
+func describe(rec *record.Recording, e *record.Event) string {
+	if e == nil {
+		return "nothing"
+	}
+	node := rec.Node(e.Node)
+	text := fmt.Sprintf("%-6s %s %s(%s)", e.Kind, e.Node, node.Title, node.Name)
+	switch e.Kind {
+	case record.TickedEvent:
+		text += " -> " + e.Status.String()
+	case record.WriteEvent:
+		text += " " + e.Write.String()
+	}
+	return text
+}
+
+func printFrame(rec *record.Recording, result *record.FrameResult) {
+	frame := result.Recorded
+	when := time.Unix(0, frame.Time).Format("15:04:05.000")
+	if frame.Halt {
+		fmt.Printf("frame %d %s halt\n", result.Frame, when)
+	} else {
+		fmt.Printf("frame %d %s %v\n", result.Frame, when, frame.Status)
+	}
+	if !*verbose && !*step {
+		return
+	}
+	for i := range frame.Input {
+		fmt.Printf("    input  %s\n", frame.Input[i].String())
+	}
+	for i := range frame.Events {
+		fmt.Printf("    %s\n", describe(rec, &frame.Events[i]))
+	}
+}
+
+func printDivergence(rec *record.Recording, result *record.FrameResult) {
+	d := result.Divergence
+	fmt.Printf("\nDIVERGENCE at frame %d, event %d\n", d.Frame, d.Event)
+	fmt.Printf("  recorded: %s\n", describe(rec, d.Expected))
+	fmt.Printf("  replayed: %s\n", describe(rec, d.Actual))
+	fmt.Println("replayed events:")
+	for i := range result.Events {
+		mark := "  "
+		if i == d.Event {
+			mark = "=>"
+		}
+		fmt.Printf("  %s %s\n", mark, describe(rec, &result.Events[i]))
+	}
+}
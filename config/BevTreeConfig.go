@@ -7,6 +7,9 @@ import (
 	"os"
 )
 
+//导出的数据格式版本，和编辑器一致
+const EditorVersion = "0.3.0"
+
 //编辑器地址@http://editor.behavior3.com/#/editor
 //节点json类型
 type BTNodeCfg struct {
@@ -15,23 +18,37 @@ type BTNodeCfg struct {
 	Category    string                 `json:"category"`
 	Title       string                 `json:"title"`
 	Description string                 `json:"description"`
-	Children    []string               `json:"children"`
-	Child       string                 `json:"child"`
-	Parameters  map[string]interface{} `json:"parameters"`
+	Children    []string               `json:"children,omitempty"`
+	Child       string                 `json:"child,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
 	Properties  map[string]interface{} `json:"properties"`
+	Display     *DisplayCfg            `json:"display,omitempty"`
+}
+
+//编辑器中节点的位置
+type DisplayCfg struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+//编辑器中树的视角和根节点的位置
+type TreeDisplayCfg struct {
+	CameraX float64 `json:"camera_x"`
+	CameraY float64 `json:"camera_y"`
+	CameraZ float64 `json:"camera_z"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
 }
 
 func (this *BTNodeCfg) GetProperty(name string) float64 {
 	v, ok := this.Properties[name]
 	if !ok {
 		panic("GetProperty err ,no value:" + name)
-		return 0
 	}
 	f64, fok := v.(float64)
 	if !fok {
 		fmt.Println("GetProperty err ,format not float64:", name, v)
 		panic("GetProperty err ,format not float64:" + name)
-		return 0
 	}
 	return f64
 }
@@ -60,7 +77,6 @@ func (this *BTNodeCfg) GetPropertyAsBool(name string) bool {
 		}
 		fmt.Println("GetProperty err ,format not bool:", name, v)
 		panic("GetProperty err ,format not bool:" + name)
-		return false
 	}
 	return b
 }
@@ -68,26 +84,27 @@ func (this *BTNodeCfg) GetPropertyAsString(name string) string {
 	v, ok := this.Properties[name]
 	if !ok {
 		panic("GetProperty err ,no vlaue:" + name)
-		return ""
 	}
 
 	str, fok := v.(string)
 	if !fok {
 		fmt.Println("GetProperty err ,format not string:", name, v)
 		panic("GetProperty err ,format not string:" + name)
-		return ""
 	}
 	return str
 }
 
 //树json类型
 type BTTreeCfg struct {
+	Version     string                 `json:"version,omitempty"`
+	Scope       string                 `json:"scope,omitempty"`
 	ID          string                 `json:"id"`
 	Title       string                 `json:"title"`
 	Description string                 `json:"description"`
 	Root        string                 `json:"root"`
 	Properties  map[string]interface{} `json:"properties"`
 	Nodes       map[string]BTNodeCfg   `json:"nodes"`
+	Display     *TreeDisplayCfg        `json:"display,omitempty"`
 }
 
 //加载
@@ -99,18 +116,26 @@ func LoadTreeCfg(relativePath string) (*BTTreeCfg, bool) {
 	}
 	filePath := fmt.Sprintf("%s/%s", wdPath, relativePath)
 
-	var tree BTTreeCfg
 	file, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		fmt.Println("fail:", err)
 		return nil, false
 	}
-	err = json.Unmarshal(file, &tree)
+	cfg, err := ParseTreeCfg(file)
 	if err != nil {
 		fmt.Println("fail, ummarshal:", err, len(file))
 		return nil, false
 	}
 
 	//fmt.Println("load tree:", tree.Title, " nodes:", len(tree.Nodes))
-	return &tree, true
+	return cfg, true
+}
+
+// ParseTreeCfg parses a tree exported by the editor.
+func ParseTreeCfg(data []byte) (*BTTreeCfg, error) {
+	var tree BTTreeCfg
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
 }
@@ -9,10 +9,23 @@ import (
 
 //工程json类型
 type BTProjectCfg struct {
-	ID     string      `json:"id"`
-	Select string      `json:"selectedTree"`
-	Scope  string      `json:"scope"`
-	Trees  []BTTreeCfg `json:"trees"`
+	Version     string          `json:"version,omitempty"`
+	ID          string          `json:"id"`
+	Select      string          `json:"selectedTree"`
+	Scope       string          `json:"scope"`
+	Trees       []BTTreeCfg     `json:"trees"`
+	CustomNodes []CustomNodeCfg `json:"custom_nodes,omitempty"`
+}
+
+//工程中声明的自定义节点
+type CustomNodeCfg struct {
+	Version     string                 `json:"version,omitempty"`
+	Scope       string                 `json:"scope"`
+	Name        string                 `json:"name"`
+	Category    string                 `json:"category"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Properties  map[string]interface{} `json:"properties"`
 }
 
 //加载
@@ -24,18 +37,26 @@ func LoadProjectCfg(relativePath string) (*BTProjectCfg, bool) {
 	}
 	filePath := fmt.Sprintf("%s/%s", wdPath, relativePath)
 
-	var project BTProjectCfg
 	file, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		fmt.Println("LoadProjectCfg fail:", err)
 		return nil, false
 	}
-	err = json.Unmarshal(file, &project)
+	cfg, err := ParseProjectCfg(file)
 	if err != nil {
 		fmt.Println("LoadProjectCfg fail, ummarshal:", err, len(file))
 		return nil, false
 	}
 
 	//fmt.Println("load tree:", tree.Title, " nodes:", len(tree.Nodes))
-	return &project, true
+	return cfg, true
+}
+
+// ParseProjectCfg parses a project exported by the editor.
+func ParseProjectCfg(data []byte) (*BTProjectCfg, error) {
+	var project BTProjectCfg
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
 }
@@ -9,9 +9,10 @@ import (
 
 //原生工程json类型
 type RawProjectCfg struct {
-	Name string       `json:"name"`
-	Data BTProjectCfg `json:"data"`
-	Path string       `json:"path"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Data        BTProjectCfg `json:"data"`
+	Path        string       `json:"path"`
 }
 
 //加载原生工程
@@ -23,18 +24,26 @@ func LoadRawProjectCfg(relativePath string) (*RawProjectCfg, bool) {
 	}
 	filePath := fmt.Sprintf("%s/%s", wdPath, relativePath)
 
-	var project RawProjectCfg
 	file, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		fmt.Println("LoadRawProjectCfg fail:", err)
 		return nil, false
 	}
-	err = json.Unmarshal(file, &project)
+	cfg, err := ParseRawProjectCfg(file)
 	if err != nil {
 		fmt.Println("LoadRawProjectCfg fail, ummarshal:", err, len(file))
 		return nil, false
 	}
 
 	//fmt.Println("load tree:", tree.Title, " nodes:", len(tree.Nodes))
-	return &project, true
+	return cfg, true
+}
+
+// ParseRawProjectCfg parses a raw project file (.b3) of the editor.
+func ParseRawProjectCfg(data []byte) (*RawProjectCfg, error) {
+	var project RawProjectCfg
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
 }
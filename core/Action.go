@@ -42,6 +42,6 @@ func (this *Action) Initialize(params *BTNodeCfg) {
 	//this.id = b3.CreateUUID()
 	this.BaseNode.Initialize(params)
 	//this.BaseNode.IBaseWorker = this
+	// properties 保留配置中的值，导出时使用
 	this.parameters = make(map[string]interface{})
-	this.properties = make(map[string]interface{})
 }
@@ -13,6 +13,7 @@ type IBaseWrapper interface {
 	_open(tick *Tick)
 	_tick(tick *Tick) b3.Status
 	_close(tick *Tick)
+	_halt(tick *Tick)
 	_exit(tick *Tick)
 }
 type IBaseNode interface {
@@ -82,6 +83,11 @@ type BaseNode struct {
 	// A dictionary (key, value) describing the node properties. Useful for
 	// defining custom variables inside the visual editor.
 	properties map[string]interface{}
+
+	// The ports declared by the node (see `IPorts`), and their mapping
+	// ("$key" or a constant) bound at load time.
+	ports   map[string]Port
+	portMap map[string]interface{}
 }
 
 func (this *BaseNode) Ctor() {
@@ -91,8 +97,8 @@ func (this *BaseNode) Ctor() {
 func (this *BaseNode) SetName(name string) {
 	this.name = name
 }
-func (this *BaseNode) SetTitle(name string) {
-	this.name = name
+func (this *BaseNode) SetTitle(title string) {
+	this.title = title
 }
 
 func (this *BaseNode) SetBaseNodeWorker(worker IBaseWorker) {
@@ -103,6 +109,24 @@ func (this *BaseNode) GetBaseNodeWorker() IBaseWorker {
 	return this.IBaseWorker
 }
 
+func (this *BaseNode) baseNode() *BaseNode {
+	return this
+}
+
+// outerNode 返回包含此BaseNode的具体节点(加载时通过SetBaseNodeWorker设置)
+func (this *BaseNode) outerNode() IBaseNode {
+	if node, ok := this.IBaseWorker.(IBaseNode); ok {
+		return node
+	}
+	return this
+}
+
+// GetPortMapping returns the mapping bound to a declared port.
+func (this *BaseNode) GetPortMapping(name string) (interface{}, bool) {
+	mapping, ok := this.portMap[name]
+	return mapping, ok
+}
+
 // nitialization method.
 func (this *BaseNode) Initialize(params *BTNodeCfg) {
 	//this.id = b3.CreateUUID()
@@ -162,6 +186,28 @@ open、close的解释：当一个节点正在执行或准备执行时，isOpen=t
  */
 func (this *BaseNode) _execute(tick *Tick) b3.Status {
 	//fmt.Println("_execute :", this.title)
+	// 注册了拦截器时，经过拦截器链执行，见Interceptor.go
+	if exec := tick._execFunc(); exec != nil {
+		return exec(tick, this.outerNode())
+	}
+	return this._lifecycle(tick)
+}
+
+// _lifecycle 执行节点的生命周期，是拦截器链的最内层
+func (this *BaseNode) _lifecycle(tick *Tick) (status b3.Status) {
+	// 设置了PanicPolicy时，恢复本节点的panic，见Recover.go
+	if policy := tick._panicPolicy(); policy != nil {
+		if tick._isDisabled(this) {
+			return b3.ERROR
+		}
+		state := tick._saveState()
+		defer func() {
+			if r := recover(); r != nil {
+				status = tick._recoverPanic(this, r, state, policy)
+			}
+		}()
+	}
+
 	// ENTER
 	this._enter(tick)
 
@@ -173,7 +219,7 @@ func (this *BaseNode) _execute(tick *Tick) b3.Status {
 
 	// TICK
 	// 执行节点的真正逻辑，返回运行的状态
-	var status = this._tick(tick)
+	status = this._tick(tick)
 
 	// CLOSE
 	// 如果节点不是Running，则将节点的isOpen标记为false。
@@ -226,7 +272,9 @@ func (this *BaseNode) _open(tick *Tick) {
 func (this *BaseNode) _tick(tick *Tick) b3.Status {
 	//fmt.Println("_tick :", this.title)
 	tick._tickNode(this)
-	return this.OnTick(tick)
+	status := this.OnTick(tick)
+	tick._tickedNode(this, status)
+	return status
 }
 
 /**
@@ -241,6 +289,29 @@ func (this *BaseNode) _close(tick *Tick) {
 	this.OnClose(tick)
 }
 
+/**
+ * Wrapper for halt method. Called instead of `_close` when the node is
+ * still RUNNING but its execution is aborted: by its parent, a timeout, a
+ * branch switch or `BehaviorTree.Halt`. `OnHalt` (see `IHaltWorker`) runs
+ * before `OnClose`.
+ * @method _halt
+ * @param {Tick} tick A tick instance.
+ * @protected
+**/
+func (this *BaseNode) _halt(tick *Tick) {
+	// halt的节点不在节点栈中：压入，OnHalt和OnClose中的端口和错误属于本节点
+	tick._nodeStack = append(tick._nodeStack, this)
+	defer func() {
+		tick._nodeStack = tick._nodeStack[:len(tick._nodeStack)-1]
+	}()
+	tick._haltNode(this)
+	// OnHalt是可选的，见IHaltWorker
+	if halter, ok := this.IBaseWorker.(IHaltWorker); ok {
+		halter.OnHalt(tick)
+	}
+	this._close(tick)
+}
+
 /**
  * Wrapper for exit method.
  * @method _exit
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	_ "fmt"
 
@@ -34,6 +35,14 @@ type IBaseWorker interface {
 	//  execution.
 	OnExit(tick *Tick)
 }
+
+// IHaltWorker is implemented by the nodes that release resources when they
+// are aborted while RUNNING (by their parent, a timeout, a branch switch or
+// `BehaviorTree.Halt`). `OnHalt` is called right before `OnClose`.
+type IHaltWorker interface {
+	OnHalt(tick *Tick)
+}
+
 type BaseWorker struct {
 }
 
@@ -62,7 +71,7 @@ func (this *BaseWorker) OnOpen(tick *Tick) {
  */
 func (this *BaseWorker) OnTick(tick *Tick) b3.Status {
 	fmt.Println("tick BaseWorker")
-	return b3.ERROR
+	return tick.Fail(errors.New("OnTick is not implemented"))
 }
 
 /**
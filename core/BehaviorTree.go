@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 
 	b3 "behavior3go"
 	"behavior3go/config"
@@ -89,7 +91,22 @@ type BehaviorTree struct {
 	// The reference to the debug instance
 	debug interface{}
 
-	dumpInfo *config.BTTreeCfg
+	// The time source used by the nodes and the blackboard
+	clock Clock
+
+	// The panic recovery policy, nil uses the default policy
+	panicPolicy *PanicPolicy
+
+	// The listeners called back during the ticks, replaced (never modified)
+	// under listenersMu so that a tick can use them without lock
+	listeners   []TickListener
+	listenersMu sync.Mutex
+
+	// The interceptors wrapping the execution of the nodes
+	interceptors []Interceptor
+
+	// ID of the loaded config, the `tree` nodes call the tree by this ID
+	cfgID string
 }
 
 func NewBeTree() *BehaviorTree {
@@ -110,20 +127,50 @@ func (this *BehaviorTree) Initialize() {
 	this.properties = make(map[string]interface{})
 	this.root = nil
 	this.debug = nil
+	this.clock = SystemClock
 }
 
 func (this *BehaviorTree) GetID() string {
 	return this.id
 }
 
+// GetCfgID returns the ID of the config the tree was loaded from, the ID
+// called by the `tree` nodes; empty if the config had no ID.
+func (this *BehaviorTree) GetCfgID() string {
+	return this.cfgID
+}
+
 func (this *BehaviorTree) GetTitile() string {
 	return this.title
 }
 
+// GetDisplayName returns the title of the tree, or its ID if it has none.
+func (this *BehaviorTree) GetDisplayName() string {
+	if this.title != "" {
+		return this.title
+	}
+	return this.id
+}
+
 func (this *BehaviorTree) SetDebug(debug interface{}) {
 	this.debug = debug
 }
 
+// SetClock replaces the time source of the tree, nil restores SystemClock.
+func (this *BehaviorTree) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	this.clock = clock
+}
+
+func (this *BehaviorTree) GetClock() Clock {
+	if this.clock == nil {
+		return SystemClock
+	}
+	return this.clock
+}
+
 func (this *BehaviorTree) GetRoot() IBaseNode {
 	return this.root
 }
@@ -157,10 +204,23 @@ func (this *BehaviorTree) GetRoot() IBaseNode {
  * @param {Object} [names] A namespace or dict containing custom nodes.
 **/
 func (this *BehaviorTree) Load(data *config.BTTreeCfg, maps *b3.RegisterStructMaps, extMaps *b3.RegisterStructMaps) {
-	this.title = data.Title             //|| this.title;
-	this.description = data.Description // || this.description;
-	this.properties = data.Properties   // || this.properties;
-	this.dumpInfo = data
+	if err := this.TryLoad(data, maps, extMaps); err != nil {
+		panic(err.Error())
+	}
+}
+
+/**
+ * TryLoad works as `Load`, and returns an error instead of panicking when
+ * the data is not a valid tree: unknown node names, invalid properties or
+ * ports, children or root that are not in the nodes, or nodes that are
+ * their own ancestor. On error the tree is left unchanged.
+ *
+ * A tree without root (`root` empty) loads, and its ticks return ERROR.
+**/
+func (this *BehaviorTree) TryLoad(data *config.BTTreeCfg, maps *b3.RegisterStructMaps, extMaps *b3.RegisterStructMaps) error {
+	if data == nil {
+		return errors.New("BehaviorTree.load: no data")
+	}
 	nodes := make(map[string]IBaseNode)
 
 	// Create the node list (without connection between them)
@@ -174,11 +234,11 @@ func (this *BehaviorTree) Load(data *config.BTTreeCfg, maps *b3.RegisterStructMa
 			if extMaps != nil && extMaps.CheckElem(nodeCfg.Name) {
 				// Look for the name in custom nodes
 				if tnode, err := extMaps.New(nodeCfg.Name); err == nil {
-					node = tnode.(IBaseNode)
+					node, _ = tnode.(IBaseNode)
 				}
-			} else {
+			} else if maps != nil {
 				if tnode, err2 := maps.New(nodeCfg.Name); err2 == nil {
-					node = tnode.(IBaseNode)
+					node, _ = tnode.(IBaseNode)
 				} else {
 					//fmt.Println("new ", nodeCfg.Name, " err:", err2)
 				}
@@ -187,57 +247,175 @@ func (this *BehaviorTree) Load(data *config.BTTreeCfg, maps *b3.RegisterStructMa
 
 		if node == nil {
 			// Invalid node name
-			panic("BehaviorTree.load: Invalid node name:" + nodeCfg.Name + ",title:" + nodeCfg.Title)
+			return errors.New("BehaviorTree.load: Invalid node name:" + nodeCfg.Name + ",title:" + nodeCfg.Title)
 
 		}
 
-		node.Ctor()
-		node.Initialize(&nodeCfg)
-		// i note:
-		// node.(IBaseWorker) 得到的是 node.BaseWorker (如Action.BaseNode、Composite.BaseNode、Condition.BaseNode)
-		// 它们作为(Action/Composite/Condition)的struct类型成员变量，在声明时都已经有值。而此处就是赋值
-		// > 在这里取node.(IBaseWorker)进行赋值最方便，符合封装思想。
-		// > 另一种赋值方式是，在每个具体node的Init中赋值。例如在log.Init中赋值，写法：`log.IBaseWorker = interface{}(log).(core.IBaseWorker)`
-		node.SetBaseNodeWorker(node.(IBaseWorker))
+		nodeCfg := nodeCfg
+		if err := initNode(node, &nodeCfg); err != nil {
+			return fmt.Errorf("BehaviorTree.load: node %s(%s): %v", nodeCfg.Title, id, err)
+		}
+		// 绑定节点声明的端口
+		if err := bindPorts(node, &nodeCfg); err != nil {
+			return errors.New("BehaviorTree.load: " + err.Error())
+		}
 		nodes[id] = node
 	}
 
 	// Connect the nodes
+	edges := make(map[string][]string)
 	for id, nodeCfg := range data.Nodes {
 		node := nodes[id]
 
 		if node.GetCategory() == b3.COMPOSITE && nodeCfg.Children != nil {
+			comp, ok := node.(IComposite)
+			if !ok {
+				return fmt.Errorf("BehaviorTree.load: node %s(%s) can not have children", nodeCfg.Title, id)
+			}
 			for i := 0; i < len(nodeCfg.Children); i++ {
 				var cid = nodeCfg.Children[i]
-				comp := node.(IComposite)
+				if nodes[cid] == nil {
+					return fmt.Errorf("BehaviorTree.load: node %s(%s) child %q not found", nodeCfg.Title, id, cid)
+				}
 				comp.AddChild(nodes[cid])
+				edges[id] = append(edges[id], cid)
 			}
 		} else if node.GetCategory() == b3.DECORATOR && len(nodeCfg.Child) > 0 {
-			dec := node.(IDecorator)
+			dec, ok := node.(IDecorator)
+			if !ok {
+				return fmt.Errorf("BehaviorTree.load: node %s(%s) can not have a child", nodeCfg.Title, id)
+			}
+			if nodes[nodeCfg.Child] == nil {
+				return fmt.Errorf("BehaviorTree.load: node %s(%s) child %q not found", nodeCfg.Title, id, nodeCfg.Child)
+			}
 			dec.SetChild(nodes[nodeCfg.Child])
+			edges[id] = append(edges[id], nodeCfg.Child)
+		}
+	}
+
+	// 节点是自己的祖先时，tick会无限递归
+	state := make(map[string]int)
+	for id := range edges {
+		if cycle, found := findCycle(edges, id, state); found {
+			return fmt.Errorf("BehaviorTree.load: node %q is its own ancestor", cycle)
 		}
 	}
 
-	this.root = nodes[data.Root]
+	root := nodes[data.Root]
+	if root == nil && data.Root != "" {
+		return fmt.Errorf("BehaviorTree.load: root %q not found", data.Root)
+	}
+
+	this.title = data.Title             //|| this.title;
+	this.description = data.Description // || this.description;
+	this.properties = data.Properties   // || this.properties;
+	this.cfgID = data.ID
+	this.root = root
+	return nil
 }
 
-/**
- * This method dump the current BT into a data structure.
- *
- * Note: This method does not record the current node parameters. Thus,
- * it may not be compatible with load for now.
- *
- * @method dump
- * @return {Object} A data object representing this tree.
-**/
-func (this *BehaviorTree) dump() *config.BTTreeCfg {
-	return this.dumpInfo
+// initNode 初始化节点，节点的Initialize读取属性失败时会panic
+func initNode(node IBaseNode, cfg *config.BTNodeCfg) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	node.Ctor()
+	node.Initialize(cfg)
+	// i note:
+	// node.(IBaseWorker) 得到的是 node.BaseWorker (如Action.BaseNode、Composite.BaseNode、Condition.BaseNode)
+	// 它们作为(Action/Composite/Condition)的struct类型成员变量，在声明时都已经有值。而此处就是赋值
+	// > 在这里取node.(IBaseWorker)进行赋值最方便，符合封装思想。
+	// > 另一种赋值方式是，在每个具体node的Init中赋值。例如在log.Init中赋值，写法：`log.IBaseWorker = interface{}(log).(core.IBaseWorker)`
+	node.SetBaseNodeWorker(node.(IBaseWorker))
+	return nil
+}
+
+// findCycle 从id深度优先查找环，返回环上的节点ID。state: 1 访问中，2 已完成
+func findCycle(edges map[string][]string, id string, state map[string]int) (string, bool) {
+	switch state[id] {
+	case 1:
+		return id, true
+	case 2:
+		return "", false
+	}
+	state[id] = 1
+	for _, cid := range edges[id] {
+		if cycle, found := findCycle(edges, cid, state); found {
+			return cycle, true
+		}
+	}
+	state[id] = 2
+	return "", false
 }
 
 func (this *BehaviorTree) Print() {
 	printNode(this.root, 0)
 }
 
+/**
+ * Walk visits the nodes of the tree depth-first, starting from the root.
+ * The callback receives each node with its depth (the root is 0), and can
+ * return false to skip the children of that node. Subtrees called by
+ * `tree` nodes are not entered. A node reachable twice is visited once.
+**/
+func (this *BehaviorTree) Walk(fn func(node IBaseNode, depth int) bool) {
+	if this.root == nil {
+		return
+	}
+	walkNode(this.root, 0, make(map[IBaseNode]bool), fn)
+}
+
+/**
+ * WalkKeys visits the nodes like `Walk`, and enters the subtrees called by
+ * `tree` nodes (found with `GetSubTree`), whose nodes are visited below
+ * their `tree` node. The callback also receives the key of each node, as
+ * `Tick.NodeKey` returns it while the tree is ticked: a subtree called from
+ * two places is visited twice, with different keys. A subtree calling
+ * itself, directly or not, is not entered again.
+**/
+func (this *BehaviorTree) WalkKeys(fn func(node IBaseNode, key string, depth int) bool) {
+	this._walkKeys("", 0, map[*BehaviorTree]bool{this: true}, fn)
+}
+
+func (this *BehaviorTree) _walkKeys(prefix string, base int, calling map[*BehaviorTree]bool, fn func(IBaseNode, string, int) bool) {
+	this.Walk(func(node IBaseNode, depth int) bool {
+		key := prefix + node.GetID()
+		if !fn(node, key, base+depth) {
+			return false
+		}
+		if _, ok := node.(*SubTree); ok {
+			sub := GetSubTree(node.GetName())
+			if sub != nil && !calling[sub] {
+				calling[sub] = true
+				sub._walkKeys(key+"/", base+depth+1, calling, fn)
+				delete(calling, sub)
+			}
+		}
+		return true
+	})
+}
+
+func walkNode(node IBaseNode, depth int, visited map[IBaseNode]bool, fn func(IBaseNode, int) bool) {
+	if node == nil || visited[node] {
+		return
+	}
+	visited[node] = true
+	if !fn(node, depth) {
+		return
+	}
+	switch node.GetCategory() {
+	case b3.COMPOSITE:
+		comp := node.(IComposite)
+		for i := 0; i < comp.GetChildCount(); i++ {
+			walkNode(comp.GetChild(i), depth+1, visited, fn)
+		}
+	case b3.DECORATOR:
+		walkNode(node.(IDecorator).GetChild(), depth+1, visited, fn)
+	}
+}
+
 /**
  * Propagates the tick signal through the tree, starting from the root.
  *
@@ -275,20 +453,45 @@ blackboard被tree和node用来存储执行变量（例如，最后一个运行
 @return：滴答信号状态。
 **/
 func (this *BehaviorTree) Tick(target interface{}, blackboard *Blackboard) b3.Status {
+	_, state := this._run(target, blackboard, nil)
+	return state
+}
+
+// _run 执行一次tick，返回tick对象和状态。extra是只用于本次tick的listener
+func (this *BehaviorTree) _run(target interface{}, blackboard *Blackboard, extra []TickListener) (*Tick, b3.Status) {
 	if blackboard == nil {
 		panic("The blackboard parameter is obligatory and must be an instance of b3.Blackboard")
 	}
 
 	// 创建tick对象
-	var tick = NewTick()
-	tick.debug = this.debug
-	tick.target = target
-	tick.Blackboard = blackboard
-	tick.tree = this
+	var tick = this._newTick(target, blackboard)
+	for _, l := range extra {
+		tick.AddListener(l)
+	}
+	blackboard._getTreeData(this.id).target = target
+	for _, l := range tick._listeners {
+		l.TickStart(tick)
+	}
+	// 节点的panic没有被PanicPolicy恢复时也调用TickEnd，listener可以清理本次tick的状态
+	ended := false
+	defer func() {
+		if !ended {
+			for _, l := range tick._listeners {
+				l.TickEnd(tick, b3.ERROR)
+			}
+		}
+	}()
+
+	// 黑板使用本树的时间，并清理过期的key
+	blackboard.SetClock(this.GetClock())
+	blackboard.PurgeExpired()
 
 	// 执行节点逻辑。内部会按照结构顺序，调用所有节点的execute
 	// 如果有running的节点
-	var state = this.root._execute(tick)
+	var state = b3.ERROR
+	if this.root != nil {
+		state = this.root._execute(tick)
+	}
 
 	// 关闭上一次tick的节点(如果需要)
 	// openNodes: 其实就是tick后处于running状态的节点；注意：一个节点处于running时，其父节点可能也处于running状态，或许会有一条"running"链
@@ -300,23 +503,20 @@ func (this *BehaviorTree) Tick(target interface{}, blackboard *Blackboard) b3.St
 	var start = 0 // 从第几个节点开始关闭
 	for i := 0; i < b3.MinInt(len(lastOpenNodes), len(currOpenNodes)); i++ {
 		start = i + 1
-		// 遍历本次和上次的running调用链，若存在状态不同的节点，则从这个节点之后的所有节点都要被关闭
+		// 遍历本次和上次的running调用链，若存在状态不同的节点，则从这个节点开始的所有节点都要被关闭
 		if lastOpenNodes[i] != currOpenNodes[i] {
+			// 不同的节点本身也要关闭：上次running的分支(如Priority切换到另一个子节点)
+			// 本次没有执行，它和它下面的节点都不会再被关闭
+			start = i
 			break
 		}
 	}
 
 	// 关闭这个节点及其所有后续节点
-	for i := len(lastOpenNodes) - 1; i >= start; i-- {
-		node := lastOpenNodes[i]
-		// 打印节点及该节点是否已被关闭。会发现打印结果是有规律的，每次运行的打印结果都是固定的
-		fmt.Println(node)
-		fmt.Println("is-open:", tick.Blackboard.Get("isOpen", tick.tree.id, node.GetID()))
-		lastOpenNodes[i]._close(tick)
-	}
+	tick._haltNodes(lastOpenNodes, start)
 
 	// todo 可运行`memsubtree/main.go`触发以下逻辑进行分析
-	// 通过上面的打印分析得出：
+	// 通过打印被关闭的节点及其isOpen分析得出：
 	//  类似这样的一个树结构：一个子树st(subtree)被主树的两个分支a、b调用。
 	// 	若本次tick通过分支a进入st，上次tick通过分支b进入st
 	//  则上次tick造成的st中的running节点就要被关闭(running节点存在于openNodes中)。
@@ -325,8 +525,10 @@ func (this *BehaviorTree) Tick(target interface{}, blackboard *Blackboard) b3.St
 	// 		- 在主观上，引用子树就是相当于把子树的节点添加到主树中。子树起到的是对子树节点结构的封装作用
 	//		- 在本引擎中，分支a、b都引用了同一个子树st，但st内节点的状态在不同分支下却都是同一个(黑板通过nodeId存放数据，不同分支引用的st中的nodeID是一样的)
 	//		- 在常规需求中我们应该更想这样：a分支下的st节点和b分支下的st节点，应该是两套节点，他们的内存应该是分开的。可以给子树节点id依据分支加上不同的前缀
+	//		  (现在子树的节点内存和isOpen按Tick.NodeKey区分，即加上了调用子树的tree节点的key作为前缀，见SubTree._enter)
 	//
 	// 冗余的触发情况：本次tick没有openNodes，上次tick有，但上次tick的openNodes在本次tick运行时已经被正常close了，也会再触发这里的close，这显然是多余的
+	// (_haltNodes 会跳过已经close的节点)
 
 	// 填充黑板数据
 	// 本次tick的openNodes保存到黑板中在下次tick时使用
@@ -334,7 +536,59 @@ func (this *BehaviorTree) Tick(target interface{}, blackboard *Blackboard) b3.St
 	// nodeCount：本次tick中，执行了_enter()的所有节点数量。没看到有什么用途
 	blackboard.SetTree("nodeCount", tick._nodeCount, this.id)
 
-	return state
+	ended = true
+	for _, l := range tick._listeners {
+		l.TickEnd(tick, state)
+	}
+	return tick, state
+}
+
+func (this *BehaviorTree) _newTick(target interface{}, blackboard *Blackboard) *Tick {
+	var tick = NewTick()
+	tick.debug = this.debug
+	tick.target = target
+	tick.Blackboard = blackboard
+	tick.tree = this
+	tick._listeners = this._tickListeners()
+	return tick
+}
+
+/**
+ * Halt stops the tree for the agent owning the blackboard: every node left
+ * open (RUNNING) by the last tick is halted (`OnHalt` then `OnClose`),
+ * deepest first, including the nodes inside subtrees, and the per-node
+ * memory of the tree is reset, with the ttl of its keys (see `SetWithTTL`).
+ * The next tick starts from scratch.
+ *
+ * Use it when an agent dies, despawns or switches to another tree. The
+ * target given to the last tick is passed again to the nodes.
+**/
+func (this *BehaviorTree) Halt(blackboard *Blackboard) {
+	if blackboard == nil {
+		panic("The blackboard parameter is obligatory and must be an instance of b3.Blackboard")
+	}
+	treeData := blackboard._getTreeData(this.id)
+	tick := this._newTick(treeData.target, blackboard)
+
+	tick._haltNodes(treeData.OpenNodes, 0)
+
+	treeData.OpenNodes = make([]IBaseNode, 0)
+	blackboard._resetNodeMemory(this.id)
+}
+
+/**
+ * RunningPath returns the chain of nodes left RUNNING by the last tick for
+ * the agent owning the blackboard, from the root to the running leaf,
+ * without ticking the tree. It is empty if the tree is not running.
+ *
+ * The nodes of a called subtree follow the `tree` node calling them
+ * (`NodeInfo.IsSubTree`), at any nesting level.
+**/
+func (this *BehaviorTree) RunningPath(blackboard *Blackboard) []NodeInfo {
+	if blackboard == nil {
+		panic("The blackboard parameter is obligatory and must be an instance of b3.Blackboard")
+	}
+	return nodeInfoPath(blackboard._getTreeData(this.id).OpenNodes)
 }
 
 func printNode(root IBaseNode, blk int) {
@@ -1,9 +1,10 @@
 package core
 
 import (
-	"fmt"
-	"reflect"
+	"sort"
+	"time"
 )
+
 /**
  * The Blackboard is the memory structure required by `BehaviorTree` and its
  * nodes. It only have 2 public methods: `set` and `get`. These methods works
@@ -77,10 +78,13 @@ type TreeData struct {
 	OpenNodes      []IBaseNode
 	TraversalDepth int
 	TraversalCycle int
+
+	// 上一次tick的target，Halt时传给节点
+	target interface{}
 }
 
 func NewTreeData() *TreeData {
-	return &TreeData{NewMemory(), make([]IBaseNode, 0), 0, 0}
+	return &TreeData{NewMemory(), make([]IBaseNode, 0), 0, 0, nil}
 }
 
 //------------------------Memory-------------------------
@@ -116,6 +120,22 @@ func NewTreeMemory() *TreeMemory {
 type Blackboard struct {
 	_baseMemory *Memory
 	_treeMemory map[string]*TreeMemory
+	_observers  *observers
+
+	// 父黑板，全局上下文的读取会向上查找
+	_parent *Blackboard
+	// 标记为共享的key，子黑板写入这些key时会写到本黑板
+	_sharedKeys map[string]bool
+
+	// 时间源，由tick的树设置；以及设置了TTL的key的过期时间
+	_clock     Clock
+	_deadlines map[scopedKey]time.Time
+
+	// 端口映射视图(见Remap)：全局key经_ports转换后访问_outer
+	_outer *Blackboard
+	_ports map[string]interface{}
+	// 子树视图中节点上下文的前缀，即调用子树的tree节点的key加"/"(见Tick.NodeKey)
+	_nodePrefix string
 }
 
 func NewBlackboard() *Blackboard {
@@ -127,6 +147,9 @@ func NewBlackboard() *Blackboard {
 func (this *Blackboard) Initialize() {
 	this._baseMemory = NewMemory()
 	this._treeMemory = make(map[string]*TreeMemory)
+	this._observers = newObservers()
+	this._sharedKeys = make(map[string]bool)
+	this._deadlines = make(map[scopedKey]time.Time)
 }
 
 /**
@@ -199,6 +222,15 @@ func (this *Blackboard) _getMemory(treeScope, nodeScope string) *Memory {
 	return memory
 }
 
+// _nodeKey 子树视图中的节点上下文加上调用子树的tree节点的key作为前缀，
+// 同一个节点ID在树和子树中(或子树被多处调用时)使用不同的内存
+func (this *Blackboard) _nodeKey(treeScope, nodeScope string) string {
+	if len(treeScope) == 0 || len(nodeScope) == 0 {
+		return nodeScope
+	}
+	return this._nodePrefix + nodeScope
+}
+
 /**
  * Stores a value in the blackboard. If treeScope and nodeScope are
  * provided, this method will save the value into the per node per tree
@@ -220,23 +252,96 @@ func (this *Blackboard) _getMemory(treeScope, nodeScope string) *Memory {
  * @param {String} nodeScope The node id if accessing the node memory.
 **/
 func (this *Blackboard) Set(key string, value interface{}, treeScope, nodeScope string) {
-	var memory = this._getMemory(treeScope, nodeScope)
-	memory.Set(key, value)
+	this._set(key, value, treeScope, nodeScope)
 }
 
 func (this *Blackboard) SetMem(key string, value interface{}) {
-	var memory = this._getMemory("", "")
-	memory.Set(key, value)
+	this._set(key, value, "", "")
 }
 
 func (this *Blackboard) Remove(key string) {
-	var memory = this._getMemory("", "")
-	memory.Remove(key)
+	this._remove(key, "", "")
 }
 func (this *Blackboard) SetTree(key string, value interface{}, treeScope string) {
-	var memory = this._getMemory(treeScope, "")
+	this._set(key, value, treeScope, "")
+}
+
+// RemoveTree removes a key of the memory of the tree treeScope.
+func (this *Blackboard) RemoveTree(key string, treeScope string) {
+	this._remove(key, treeScope, "")
+}
+
+// _set 写入值并通知observer
+func (this *Blackboard) _set(key string, value interface{}, treeScope, nodeScope string) {
+	this._setUntil(key, value, treeScope, nodeScope, time.Time{})
+}
+
+// _setUntil 写入值，deadline不为零时到期后key被视为不存在
+func (this *Blackboard) _setUntil(key string, value interface{}, treeScope, nodeScope string, deadline time.Time) {
+	if len(treeScope) == 0 && this._outer != nil {
+		// 常量端口只读
+		if board, outKey, _, isConst := this._resolve(key); !isConst {
+			board._setUntil(outKey, value, "", "", deadline)
+		}
+		return
+	}
+	if len(treeScope) == 0 {
+		if owner := this._sharedOwner(key); owner != nil && owner != this {
+			owner._setUntil(key, value, "", "", deadline)
+			return
+		}
+	}
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	var memory = this._getMemory(treeScope, nodeScope)
+	old := memory.Get(key)
 	memory.Set(key, value)
+	if len(treeScope) == 0 {
+		nodeScope = ""
+	}
+	this._setDeadline(scopedKey{key, treeScope, nodeScope}, deadline)
+	if !sameValue(old, value) {
+		this._observers.notify(BlackboardChange{Key: key, TreeScope: treeScope, NodeScope: nodeScope, Old: old, New: value})
+	}
 }
+
+// _remove 删除值并通知observer
+func (this *Blackboard) _remove(key string, treeScope, nodeScope string) {
+	if len(treeScope) == 0 && this._outer != nil {
+		if board, outKey, _, isConst := this._resolve(key); !isConst {
+			board._remove(outKey, "", "")
+		}
+		return
+	}
+	if len(treeScope) == 0 {
+		if owner := this._sharedOwner(key); owner != nil && owner != this {
+			owner._remove(key, "", "")
+			return
+		}
+	}
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	var memory = this._getMemory(treeScope, nodeScope)
+	old, ok := memory._memory[key]
+	if !ok {
+		return
+	}
+	memory.Remove(key)
+	if len(treeScope) == 0 {
+		nodeScope = ""
+	}
+	delete(this._deadlines, scopedKey{key, treeScope, nodeScope})
+	this._observers.notify(BlackboardChange{Key: key, TreeScope: treeScope, NodeScope: nodeScope, Old: old, Removed: true})
+}
+
+// _resetNodeMemory 丢弃一棵树所有节点的内存，以及其中key的过期时间
+func (this *Blackboard) _resetNodeMemory(treeScope string) {
+	this._getTreeMemory(treeScope)._nodeMemory = make(map[string]*Memory)
+	for key := range this._deadlines {
+		if key.treeScope == treeScope && len(key.nodeScope) > 0 {
+			delete(this._deadlines, key)
+		}
+	}
+}
+
 func (this *Blackboard) _getTreeData(treeScope string) *TreeData {
 	treeMem := this._getTreeMemory(treeScope)
 	return treeMem._treeData
@@ -264,19 +369,49 @@ func (this *Blackboard) _getTreeData(treeScope string) *TreeData {
  * @return {Object} The value stored or undefined.
 **/
 func (this *Blackboard) Get(key, treeScope, nodeScope string) interface{} {
+	if len(treeScope) == 0 {
+		return this._getGlobal(key)
+	}
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	this._expireIfDue(scopedKey{key, treeScope, nodeScope}, this)
 	memory := this._getMemory(treeScope, nodeScope)
 	return memory.Get(key)
 }
 func (this *Blackboard) GetMem(key string) interface{} {
-	memory := this._getMemory("","")
-	return memory.Get(key)
+	return this._getGlobal(key)
+}
+
+/**
+ * Range calls fn for every key stored in one context of the blackboard (the
+ * scopes follow `Set`), in key order, until fn returns false. Only the keys
+ * stored in this blackboard are visited, not the ones of its parent, and
+ * expired keys are skipped. fn must not modify the blackboard.
+**/
+func (this *Blackboard) Range(fn func(key string, value interface{}) bool, scope ...string) {
+	treeScope, nodeScope := splitScope(scope)
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	memory := this._getMemory(treeScope, nodeScope)
+	keys := make([]string, 0, len(memory._memory))
+	for key := range memory._memory {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	now := this.GetClock().Now()
+	for _, key := range keys {
+		if deadline, ok := this._deadlines[scopedKey{key, treeScope, nodeScope}]; ok && !now.Before(deadline) {
+			continue
+		}
+		if !fn(key, memory._memory[key]) {
+			return
+		}
+	}
 }
 func (this *Blackboard) GetFloat64(key, treeScope, nodeScope string) float64 {
 	v := this.Get(key, treeScope, nodeScope)
 	if v == nil {
 		return 0
 	}
-	return v.(float64)
+	return ReadNumber[float64](v)
 }
 func (this *Blackboard) GetBool(key, treeScope, nodeScope string) bool {
 	v := this.Get(key, treeScope, nodeScope)
@@ -290,21 +425,21 @@ func (this *Blackboard) GetInt(key, treeScope, nodeScope string) int {
 	if v == nil {
 		return 0
 	}
-	return v.(int)
+	return ReadNumber[int](v)
 }
 func (this *Blackboard) GetInt64(key, treeScope, nodeScope string) int64 {
 	v := this.Get(key, treeScope, nodeScope)
 	if v == nil {
 		return 0
 	}
-	return v.(int64)
+	return ReadNumber[int64](v)
 }
 func (this *Blackboard) GetUInt64(key, treeScope, nodeScope string) uint64 {
 	v := this.Get(key, treeScope, nodeScope)
 	if v == nil {
 		return 0
 	}
-	return v.(uint64)
+	return ReadNumber[uint64](v)
 }
 
 func (this *Blackboard) GetInt64Safe(key, treeScope, nodeScope string) int64 {
@@ -327,62 +462,27 @@ func (this *Blackboard) GetInt32(key, treeScope, nodeScope string) int32 {
 	if v == nil {
 		return 0
 	}
-	return v.(int32)
+	return ReadNumber[int32](v)
 }
 
-func ReadNumberToInt64(v interface{})  int64 {
-	var ret int64
-	switch tvalue := v.(type) {
-	case uint64:
-		ret = int64(tvalue)
-	default:
-		panic(fmt.Sprintf("错误的类型转成Int64 %v:%+v", reflect.TypeOf(v), v))
-	}
-
-	return ret
+// 以下函数将任意数值类型转换为目标类型，与Go的类型转换相同，溢出时回绕(例如int64(-1)转成UInt64为最大值)；
+// 不是数值时panic。需要检查范围时使用ReadNumber或ConvertNumber
+func ReadNumberToInt64(v interface{}) int64 {
+	return wrapNumber[int64](v)
 }
 
 func ReadNumberToUInt64(v interface{}) uint64 {
-	var ret uint64
-	switch tvalue := v.(type) {
-	case int64:
-		ret = uint64(tvalue)
-	default:
-		panic(fmt.Sprintf("错误的类型转成UInt64 %v:%+v", reflect.TypeOf(v), v))
-	}
-	return ret
-}
-//
-//func ReadNumberToInt32(v interface{}) int32 {
-//	var ret int32
-//	switch tvalue := v.(type) {
-//	case uint16, int16,uint32, int32,uint64,int64,uint16, int16,int:
-//		ret = int32(tvalue)
-//	default:
-//		panic(fmt.Sprintf("错误的类型转成Int32 %v:%+v", reflect.TypeOf(v), v))
-//	}
-//	return ret
-//}
-//
-//func ReadNumberToUInt32(v interface{}) uint32 {
-//	var ret uint32
-//	switch tvalue := v.(type) {
-//	case uint16, int16,uint32, int32,uint64,int64,uint16, int16,int:
-//		ret = uint32(tvalue)
-//	default:
-//		panic(fmt.Sprintf("错误的类型转成UInt32 %v:%+v", reflect.TypeOf(v), v))
-//	}
-//	return ret
-//}
-//
-//
-//func ReadNumberToInt(v interface{}) int {
-//	var ret int
-//	switch tvalue := v.(type) {
-//	case uint16, int16,uint32, int32,uint64,int64,uint16, int16,int:
-//		ret = int(tvalue)
-//	default:
-//		panic(fmt.Sprintf("int %v:%+v", reflect.TypeOf(v), v))
-//	}
-//	return ret
-//}
\ No newline at end of file
+	return wrapNumber[uint64](v)
+}
+
+func ReadNumberToInt32(v interface{}) int32 {
+	return wrapNumber[int32](v)
+}
+
+func ReadNumberToUInt32(v interface{}) uint32 {
+	return wrapNumber[uint32](v)
+}
+
+func ReadNumberToInt(v interface{}) int {
+	return wrapNumber[int](v)
+}
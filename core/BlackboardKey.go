@@ -0,0 +1,311 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/**
+ * Typed blackboard keys.
+ *
+ * A project declares the keys its nodes share in a `KeySchema`, once, with
+ * their type and scope:
+ *
+ *     var schema = core.NewKeySchema()
+ *     var TargetID = core.Declare[int64](schema, "target")
+ *     var TargetPos = core.Declare[Vec2](schema, "targetPos")
+ *
+ * Nodes then read and write through the returned `Key[T]` handle instead of
+ * raw strings, and report the keys they use by implementing
+ * `IBlackboardKeys`:
+ *
+ *     func (this *Chase) BlackboardKeys() []core.KeyUsage {
+ *       return []core.KeyUsage{TargetPos.Reads()}
+ *     }
+ *
+ * After the trees of the project are loaded, `schema.Check(trees...)` walks
+ * every node and reports keys used with a type or scope that doesn't match
+ * the declaration, or used with different types by different nodes.
+ *
+ * (Go methods can't have type parameters, so declaration is
+ * `Declare[T](schema, name)` rather than `bb.Declare[T](name)`.)
+**/
+
+// KeyScope is the blackboard context a key lives in.
+type KeyScope uint8
+
+const (
+	GlobalScope KeyScope = iota // Blackboard.Get(key, "", "")
+	TreeScope                   // Blackboard.Get(key, tree.id, "")
+	NodeScope                   // Blackboard.Get(key, tree.id, node.id)
+)
+
+func (s KeyScope) String() string {
+	switch s {
+	case GlobalScope:
+		return "global"
+	case TreeScope:
+		return "tree"
+	case NodeScope:
+		return "node"
+	}
+	return fmt.Sprintf("KeyScope(%d)", uint8(s))
+}
+
+// KeyAccess tells whether a node reads or writes a key.
+type KeyAccess uint8
+
+const (
+	KeyRead KeyAccess = 1 << iota
+	KeyWrite
+)
+
+func (a KeyAccess) String() string {
+	switch a {
+	case KeyRead:
+		return "read"
+	case KeyWrite:
+		return "write"
+	case KeyRead | KeyWrite:
+		return "read/write"
+	}
+	return "none"
+}
+
+// KeyUsage describes one blackboard key used by a node.
+type KeyUsage struct {
+	Name   string
+	Type   reflect.Type
+	Scope  KeyScope
+	Access KeyAccess
+}
+
+// ReadKey describes a key of type T read by a node.
+func ReadKey[T any](name string, scope KeyScope) KeyUsage {
+	return KeyUsage{name, typeOf[T](), scope, KeyRead}
+}
+
+// WriteKey describes a key of type T written by a node.
+func WriteKey[T any](name string, scope KeyScope) KeyUsage {
+	return KeyUsage{name, typeOf[T](), scope, KeyWrite}
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// IBlackboardKeys is implemented by nodes that report the blackboard keys
+// they read and write, so they can be checked by `KeySchema.Check`.
+type IBlackboardKeys interface {
+	BlackboardKeys() []KeyUsage
+}
+
+//------------------------Key-------------------------
+
+// Key is a typed handle to a declared blackboard key.
+type Key[T any] struct {
+	name  string
+	scope KeyScope
+}
+
+// NewKey creates a key handle without declaring it in a schema.
+func NewKey[T any](name string, scope KeyScope) Key[T] {
+	return Key[T]{name, scope}
+}
+
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+func (k Key[T]) Scope() KeyScope {
+	return k.scope
+}
+
+// Reads describes a read of this key, for `IBlackboardKeys`.
+func (k Key[T]) Reads() KeyUsage {
+	return ReadKey[T](k.name, k.scope)
+}
+
+// Writes describes a write of this key, for `IBlackboardKeys`.
+func (k Key[T]) Writes() KeyUsage {
+	return WriteKey[T](k.name, k.scope)
+}
+
+// scopeOf 根据key的作用域取得黑板的treeScope和nodeScope
+func (k Key[T]) scopeOf(tick *Tick, node IBaseNode) []string {
+	switch k.scope {
+	case TreeScope:
+		return []string{tick.GetTree().GetID()}
+	case NodeScope:
+		return []string{tick.GetTree().GetID(), node.GetID()}
+	}
+	return nil
+}
+
+// TryGet reads the key for the given node during a tick.
+func (k Key[T]) TryGet(tick *Tick, node IBaseNode) (T, bool) {
+	return TryGet[T](tick.Blackboard, k.name, k.scopeOf(tick, node)...)
+}
+
+// Get reads the key for the given node during a tick, returning the zero
+// value of T if it is absent.
+func (k Key[T]) Get(tick *Tick, node IBaseNode) T {
+	v, _ := k.TryGet(tick, node)
+	return v
+}
+
+// Set writes the key for the given node during a tick.
+func (k Key[T]) Set(tick *Tick, node IBaseNode, value T) {
+	Set(tick.Blackboard, k.name, value, k.scopeOf(tick, node)...)
+}
+
+//------------------------KeySchema-------------------------
+
+// KeySchema holds the blackboard keys declared by a project.
+type KeySchema struct {
+	keys map[string]KeyUsage
+}
+
+func NewKeySchema() *KeySchema {
+	return &KeySchema{make(map[string]KeyUsage)}
+}
+
+// Declare declares a key of type T in the schema and returns its handle. The
+// scope defaults to GlobalScope. Declaring the same name twice with a
+// different type or scope panics.
+func Declare[T any](schema *KeySchema, name string, scope ...KeyScope) Key[T] {
+	s := GlobalScope
+	if len(scope) > 0 {
+		s = scope[0]
+	}
+	usage := KeyUsage{name, typeOf[T](), s, KeyRead | KeyWrite}
+	if old, ok := schema.keys[name]; ok && (old.Type != usage.Type || old.Scope != usage.Scope) {
+		panic(fmt.Sprintf("KeySchema.Declare: key %q already declared as %v (%v), redeclared as %v (%v)",
+			name, old.Type, old.Scope, usage.Type, usage.Scope))
+	}
+	schema.keys[name] = usage
+	return Key[T]{name, s}
+}
+
+// Lookup returns the declaration of a key.
+func (this *KeySchema) Lookup(name string) (KeyUsage, bool) {
+	k, ok := this.keys[name]
+	return k, ok
+}
+
+// KeyCheckError lists every problem found by `KeySchema.Check`.
+type KeyCheckError struct {
+	Problems []string
+}
+
+func (this *KeyCheckError) Error() string {
+	return "blackboard key check failed:\n\t" + strings.Join(this.Problems, "\n\t")
+}
+
+type keyUser struct {
+	tree  *BehaviorTree
+	node  IBaseNode
+	key   string // Tick.NodeKey
+	usage KeyUsage
+}
+
+func (u keyUser) String() string {
+	return fmt.Sprintf("%s/%s(%s) %s %v", u.tree.GetTitile(), u.node.GetTitle(), u.key, u.usage.Access, u.usage.Type)
+}
+
+/**
+ * Check walks the given trees and verifies the keys reported by nodes
+ * implementing `IBlackboardKeys`, and the keys bound to typed node ports:
+ *
+ * - a declared key must be used with its declared type and scope;
+ * - an undeclared key must be used with the same type by every node.
+ *
+ * The subtrees called by the trees are checked with them (see
+ * `BehaviorTree.WalkKeys`): a subtree runs in the memory of the tree
+ * ticked, and its global keys are translated by the port mapping of the
+ * `tree` nodes calling it. Tree scoped keys are private to each ticked
+ * tree and node scoped keys to each node, so undeclared tree scoped keys
+ * are only compared within a tree and the subtrees it calls, and node
+ * scoped keys only against the declaration. It returns a *KeyCheckError or
+ * nil.
+**/
+func (this *KeySchema) Check(trees ...*BehaviorTree) error {
+	var problems []string
+	users := make(map[string][]keyUser)
+
+	for _, tree := range trees {
+		// 根到当前节点的路径，用来找到调用子树的tree节点
+		var path []IBaseNode
+		tree.WalkKeys(func(node IBaseNode, key string, depth int) bool {
+			path = append(path[:depth], node)
+			for _, usage := range nodeKeyUsages(node) {
+				if usage.Type == nil {
+					// 未指定类型的端口不参与检查
+					continue
+				}
+				user := keyUser{tree, node, key, usage}
+				if decl, ok := this.keys[usage.Name]; ok {
+					if decl.Type != usage.Type || decl.Scope != usage.Scope {
+						problems = append(problems, fmt.Sprintf("key %q declared as %v (%v): %v (%v)",
+							usage.Name, decl.Type, decl.Scope, user, usage.Scope))
+					}
+					continue
+				}
+				switch usage.Scope {
+				case GlobalScope:
+					if name, ok := resolvePorts(path[:depth], usage.Name); ok {
+						users["global:"+name] = append(users["global:"+name], user)
+					}
+				case TreeScope:
+					// 子树使用被tick的树的内存，按根树分组
+					id := "tree:" + tree.GetID() + ":" + usage.Name
+					users[id] = append(users[id], user)
+				}
+			}
+			return true
+		})
+	}
+
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		list := users[id]
+		for _, user := range list[1:] {
+			if user.usage.Type != list[0].usage.Type {
+				problems = append(problems, fmt.Sprintf("key %q used with different types: %v; %v",
+					list[0].usage.Name, list[0], user))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &KeyCheckError{problems}
+	}
+	return nil
+}
+
+// resolvePorts 按调用子树的tree节点的端口映射(从内到外)转换全局key，
+// 映射为常量的key不在黑板中，返回false
+func resolvePorts(path []IBaseNode, name string) (string, bool) {
+	for i := len(path) - 1; i >= 0; i-- {
+		st, ok := path[i].(*SubTree)
+		if !ok {
+			continue
+		}
+		port, ok := st.GetPorts()[name]
+		if !ok {
+			continue
+		}
+		ref, isRef := PortRef(port)
+		if !isRef {
+			return "", false
+		}
+		name = ref
+	}
+	return name, true
+}
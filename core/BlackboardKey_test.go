@@ -0,0 +1,161 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	b3 "behavior3go"
+	"behavior3go/config"
+)
+
+// keyGroup 只用来组织keyNode的组合节点
+type keyGroup struct {
+	Composite
+}
+
+// keyNode 按属性key、type(int或string)和scope(global或tree)报告一次读取
+type keyNode struct {
+	Action
+	usage KeyUsage
+}
+
+func (this *keyNode) Initialize(setting *config.BTNodeCfg) {
+	this.Action.Initialize(setting)
+	this.usage = ReadKey[int](setting.GetPropertyAsString("key"), GlobalScope)
+	if setting.GetPropertyAsString("type") == "string" {
+		this.usage = ReadKey[string](this.usage.Name, GlobalScope)
+	}
+	if setting.GetPropertyAsString("scope") == "tree" {
+		this.usage.Scope = TreeScope
+	}
+}
+
+func (this *keyNode) BlackboardKeys() []KeyUsage {
+	return []KeyUsage{this.usage}
+}
+
+// keyTree 创建一棵树，每个元素是一个keyNode的"key type scope"，
+// 或者调用子树的"tree 子树ID port=value..."
+func keyTree(t *testing.T, title string, usages ...string) *BehaviorTree {
+	cfg := &config.BTTreeCfg{
+		ID:    title,
+		Title: title,
+		Root:  "root",
+		Nodes: map[string]config.BTNodeCfg{"root": {Id: "root", Name: "keyGroup", Title: "root"}},
+	}
+	root := cfg.Nodes["root"]
+	for _, usage := range usages {
+		fields := strings.Fields(usage)
+		if fields[0] == "tree" {
+			id := "tree." + fields[1]
+			ports := make(map[string]interface{})
+			for _, port := range fields[2:] {
+				kv := strings.SplitN(port, "=", 2)
+				if n, err := strconv.Atoi(kv[1]); err == nil {
+					ports[kv[0]] = n
+				} else {
+					ports[kv[0]] = kv[1]
+				}
+			}
+			root.Children = append(root.Children, id)
+			cfg.Nodes[id] = config.BTNodeCfg{Id: id, Name: fields[1], Category: "tree", Title: id, Properties: ports}
+			continue
+		}
+		id := fields[0] + "." + fields[1] + "." + fields[2]
+		root.Children = append(root.Children, id)
+		cfg.Nodes[id] = config.BTNodeCfg{Id: id, Name: "keyNode", Title: id, Properties: map[string]interface{}{
+			"key": fields[0], "type": fields[1], "scope": fields[2],
+		}}
+	}
+	cfg.Nodes["root"] = root
+
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("keyGroup", new(keyGroup))
+	maps.Register("keyNode", new(keyNode))
+	tree := NewBeTree()
+	if err := tree.TryLoad(cfg, maps, nil); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestKeySchemaCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		trees    [][]string
+		subtrees map[string][]string
+		want     []string
+	}{
+		{
+			name:  "declared type mismatch",
+			trees: [][]string{{"hp string global"}},
+			want:  []string{`key "hp" declared as int (global): t0/hp.string.global(hp.string.global) read string (global)`},
+		},
+		{
+			name:  "scope conflict",
+			trees: [][]string{{"hp int tree"}},
+			want:  []string{`key "hp" declared as int (global): t0/hp.int.tree(hp.int.tree) read int (tree)`},
+		},
+		{
+			name:  "undeclared types in one tree",
+			trees: [][]string{{"mark int tree", "mark string tree"}},
+			want:  []string{`key "mark" used with different types: t0/mark.int.tree(mark.int.tree) read int; t0/mark.string.tree(mark.string.tree) read string`},
+		},
+		{
+			name:  "undeclared global in different trees",
+			trees: [][]string{{"mark int global"}, {"mark string global"}},
+			want:  []string{`key "mark" used with different types: t0/mark.int.global(mark.int.global) read int; t1/mark.string.global(mark.string.global) read string`},
+		},
+		{
+			name:  "same tree key in different trees",
+			trees: [][]string{{"mark int tree"}, {"mark string tree"}},
+		},
+		{
+			name:     "tree key in a subtree",
+			trees:    [][]string{{"mark int tree", "tree sub"}},
+			subtrees: map[string][]string{"sub": {"mark string tree"}},
+			want:     []string{`key "mark" used with different types: t0/mark.int.tree(mark.int.tree) read int; t0/mark.string.tree(tree.sub/mark.string.tree) read string`},
+		},
+		{
+			name:     "global key through a port",
+			trees:    [][]string{{"enemy int global", "tree sub target=$enemy"}},
+			subtrees: map[string][]string{"sub": {"target string global"}},
+			want:     []string{`key "enemy" used with different types: t0/enemy.int.global(enemy.int.global) read int; t0/target.string.global(tree.sub/target.string.global) read string`},
+		},
+		{
+			name:     "constant port",
+			trees:    [][]string{{"target int global", "tree sub target=3"}},
+			subtrees: map[string][]string{"sub": {"target string global"}},
+		},
+		{
+			name:  "clean tree",
+			trees: [][]string{{"hp int global", "mark int tree", "mark int tree", "name string global"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subtrees := make(map[string]*BehaviorTree)
+			for id, usages := range tt.subtrees {
+				subtrees[id] = keyTree(t, id, usages...)
+			}
+			SetSubTreeLoadFunc(func(id string) *BehaviorTree { return subtrees[id] })
+			defer SetSubTreeLoadFunc(nil)
+
+			schema := NewKeySchema()
+			Declare[int](schema, "hp")
+			var trees []*BehaviorTree
+			for i, usages := range tt.trees {
+				trees = append(trees, keyTree(t, "t"+string(rune('0'+i)), usages...))
+			}
+			err := schema.Check(trees...)
+			var got []string
+			if err != nil {
+				got = err.(*KeyCheckError).Problems
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+)
+
+/**
+ * Remapped blackboard views, used by `SubTree` port mappings.
+ *
+ * A view shares every memory of the blackboard it was made from, but
+ * translates the keys of the global context through a port table:
+ *
+ * - `"target": "$enemy"` reads and writes of `target` go to `enemy`;
+ * - `"speed": 3` reads of `speed` return 3, writes are ignored;
+ * - `"$$text"` is the constant string `"$text"`;
+ * - keys without a port are passed through unchanged.
+ *
+ * `SubTree` nodes map their properties this way, except the strings not
+ * starting with `$`, which stay plain properties: string constants are
+ * written `"$$text"`.
+ *
+ * Views nest: the `$` references of a view are resolved in the blackboard
+ * (or view) it was made from. The tree context is not translated. In the
+ * view a subtree runs in, the node contexts are prefixed by the key of the
+ * `tree` node calling it (see `Tick.NodeKey`): node 1 of the subtree called
+ * by node 3 uses the node memory "3/1", apart from the node 1 of the tree.
+**/
+
+// PortRef returns the key referenced by a port value of the form "$key".
+func PortRef(port interface{}) (string, bool) {
+	str, ok := port.(string)
+	if !ok || !strings.HasPrefix(str, "$") || strings.HasPrefix(str, "$$") || len(str) < 2 {
+		return "", false
+	}
+	return str[1:], true
+}
+
+// portConst returns the constant value of a port, unescaping "$$".
+func portConst(port interface{}) interface{} {
+	if str, ok := port.(string); ok && strings.HasPrefix(str, "$$") {
+		return str[1:]
+	}
+	return port
+}
+
+// Remap returns a view of the blackboard whose global keys are translated
+// through ports. An empty port table returns the blackboard itself.
+func (this *Blackboard) Remap(ports map[string]interface{}) *Blackboard {
+	if len(ports) == 0 {
+		return this
+	}
+	view := *this
+	view._outer = this
+	view._ports = ports
+	return &view
+}
+
+// _subtreeView 子树执行时的黑板视图：全局key经端口映射，节点上下文加上prefix。
+// 与Remap不同，没有端口时也返回视图
+func (this *Blackboard) _subtreeView(ports map[string]interface{}, prefix string) *Blackboard {
+	view := *this
+	view._outer = this
+	view._ports = ports
+	view._nodePrefix = prefix
+	return &view
+}
+
+// Base returns the blackboard a view was made from, following nested views,
+// or the blackboard itself if it is not a view. Inside a subtree
+// `tick.Blackboard` is a view: Base gives the blackboard of the agent.
+func (this *Blackboard) Base() *Blackboard {
+	board := this
+	for board._outer != nil {
+		board = board._outer
+	}
+	return board
+}
+
+// _resolve 将view中的全局key转换为外层黑板的key；isConst表示该端口是常量
+func (this *Blackboard) _resolve(key string) (board *Blackboard, outKey string, constant interface{}, isConst bool) {
+	board, outKey = this, key
+	for board._outer != nil {
+		if port, ok := board._ports[outKey]; ok {
+			ref, isRef := PortRef(port)
+			if !isRef {
+				return board, outKey, portConst(port), true
+			}
+			outKey = ref
+		}
+		board = board._outer
+	}
+	return board, outKey, nil, false
+}
@@ -0,0 +1,123 @@
+package core
+
+/**
+ * Hierarchical blackboards.
+ *
+ * A blackboard can have a parent, for example one team blackboard shared by
+ * the agents of a squad:
+ *
+ *     team := core.NewBlackboard()
+ *     team.MarkShared("enemy", "rallyPoint")
+ *
+ *     agent := core.NewChildBlackboard(team)
+ *     agent.SetMem("hp", 100)        // local to the agent
+ *     agent.SetMem("enemy", id)      // marked shared: written to team
+ *     agent.GetMem("rallyPoint")     // not local: read from team
+ *
+ * Only the global context is layered: reading a global key that is not in
+ * the local memory falls through to the parent, and writing a key marked
+ * shared on an ancestor goes to that ancestor. The tree and node contexts
+ * hold the execution state of one agent (open nodes, node memory) and
+ * always stay local.
+ *
+ * `SetShared`, `GetShared` and `RemoveShared` address the parent layer
+ * explicitly. Observers registered on a board see the writes stored in that
+ * board, including the ones made through its children.
+**/
+
+// NewChildBlackboard creates a blackboard whose global reads fall through to
+// parent.
+func NewChildBlackboard(parent *Blackboard) *Blackboard {
+	p := NewBlackboard()
+	p._parent = parent
+	return p
+}
+
+func (this *Blackboard) SetParent(parent *Blackboard) {
+	for p := parent; p != nil; p = p._parent {
+		if p == this {
+			panic("Blackboard.SetParent: cycle in blackboard hierarchy")
+		}
+	}
+	this._parent = parent
+}
+
+func (this *Blackboard) GetParent() *Blackboard {
+	return this._parent
+}
+
+// MarkShared marks keys as owned by this blackboard: global writes of these
+// keys from any descendant are stored here.
+func (this *Blackboard) MarkShared(keys ...string) {
+	if this._sharedKeys == nil {
+		this._sharedKeys = make(map[string]bool)
+	}
+	for _, key := range keys {
+		this._sharedKeys[key] = true
+	}
+}
+
+// UnmarkShared reverts `MarkShared`. Values already stored are kept.
+func (this *Blackboard) UnmarkShared(keys ...string) {
+	for _, key := range keys {
+		delete(this._sharedKeys, key)
+	}
+}
+
+// IsShared tells whether a global write of key from this blackboard is
+// stored on an ancestor.
+func (this *Blackboard) IsShared(key string) bool {
+	owner := this._sharedOwner(key)
+	return owner != nil && owner != this
+}
+
+// SetShared writes key in the parent layer, or locally without a parent.
+func (this *Blackboard) SetShared(key string, value interface{}) {
+	this._sharedLayer()._set(key, value, "", "")
+}
+
+// GetShared reads key from the parent layer (and its ancestors), ignoring
+// the local memory.
+func (this *Blackboard) GetShared(key string) interface{} {
+	return this._sharedLayer()._getGlobal(key)
+}
+
+// RemoveShared removes key from the parent layer.
+func (this *Blackboard) RemoveShared(key string) {
+	this._sharedLayer()._remove(key, "", "")
+}
+
+func (this *Blackboard) _sharedLayer() *Blackboard {
+	if this._parent != nil {
+		return this._parent
+	}
+	return this
+}
+
+// _sharedOwner 返回标记了key为共享的最近的黑板(包括自己)，没有则返回nil
+func (this *Blackboard) _sharedOwner(key string) *Blackboard {
+	for p := this; p != nil; p = p._parent {
+		if p._sharedKeys[key] {
+			return p
+		}
+	}
+	return nil
+}
+
+// _getGlobal 读取全局上下文，本地没有时向父黑板查找
+func (this *Blackboard) _getGlobal(key string) interface{} {
+	if this._outer != nil {
+		board, outKey, constant, isConst := this._resolve(key)
+		if isConst {
+			return constant
+		}
+		return board._getGlobal(outKey)
+	}
+	for p := this; p != nil; p = p._parent {
+		p._expireIfDue(scopedKey{key, "", ""}, this)
+		if v, ok := p._baseMemory._memory[key]; ok {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+/**
+ * Blackboard key expiration.
+ *
+ * `SetWithTTL` stores a value that is treated as absent once the ttl has
+ * elapsed, which suits perception data such as "last seen enemy position":
+ *
+ *     bb.SetWithTTL("enemyPos", pos, 3*time.Second)
+ *     ...
+ *     if bb.GetMem("enemyPos") == nil { // not seen for 3 seconds
+ *
+ * Time is read from the blackboard clock. `BehaviorTree.Tick` sets it to the
+ * clock of the tree (see `BehaviorTree.SetClock`) and purges the expired
+ * keys before running the nodes, so `OnExpired` observers fire once per key
+ * even if nobody reads it. Expired keys are also dropped lazily when read.
+ *
+ * Writing the key again with `Set` (or `SetMem`, `SetTree`) clears its ttl.
+**/
+
+// SetClock sets the time source used for key expiration. Trees set it to
+// their own clock on every tick.
+func (this *Blackboard) SetClock(clock Clock) {
+	this._clock = clock
+}
+
+func (this *Blackboard) GetClock() Clock {
+	if this._clock == nil {
+		return SystemClock
+	}
+	return this._clock
+}
+
+/**
+ * SetWithTTL stores a value that expires after ttl. The optional scope
+ * arguments are the tree scope and the node scope, as in `Set`.
+ *
+ * @param {String} key The key to be stored.
+ * @param {Object} value The value to be stored.
+ * @param {Duration} ttl How long the value lives.
+ * @param {String} scope Optional tree id and node id.
+**/
+func (this *Blackboard) SetWithTTL(key string, value interface{}, ttl time.Duration, scope ...string) {
+	treeScope, nodeScope := splitScope(scope)
+	this._setUntil(key, value, treeScope, nodeScope, this.GetClock().Now().Add(ttl))
+}
+
+// ExpiresAt returns the expiration time of a key set with `SetWithTTL`.
+func (this *Blackboard) ExpiresAt(key string, scope ...string) (time.Time, bool) {
+	treeScope, nodeScope := splitScope(scope)
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	board := this
+	if len(treeScope) == 0 {
+		nodeScope = ""
+		if owner := this._sharedOwner(key); owner != nil {
+			board = owner
+		}
+	}
+	deadline, ok := board._deadlines[scopedKey{key, treeScope, nodeScope}]
+	return deadline, ok
+}
+
+// OnExpired registers an observer called when a key set with `SetWithTTL`
+// expires. The change has `Removed` and `Expired` set.
+func (this *Blackboard) OnExpired(fn func(change BlackboardChange)) WatchID {
+	return this._observers.add(&watcher{all: true, expired: true, fn: fn})
+}
+
+// PurgeExpired removes every expired key now, firing the observers.
+func (this *Blackboard) PurgeExpired() {
+	if len(this._deadlines) == 0 {
+		return
+	}
+	now := this.GetClock().Now()
+	var due []scopedKey
+	for key, deadline := range this._deadlines {
+		if !now.Before(deadline) {
+			due = append(due, key)
+		}
+	}
+	// 按过期时间排序，保证事件顺序稳定
+	sort.Slice(due, func(i, j int) bool {
+		di, dj := this._deadlines[due[i]], this._deadlines[due[j]]
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return due[i].key < due[j].key
+	})
+	for _, key := range due {
+		this._expire(key)
+	}
+}
+
+func (this *Blackboard) _setDeadline(key scopedKey, deadline time.Time) {
+	if deadline.IsZero() {
+		delete(this._deadlines, key)
+		return
+	}
+	if this._deadlines == nil {
+		this._deadlines = make(map[scopedKey]time.Time)
+	}
+	this._deadlines[key] = deadline
+}
+
+// _expireIfDue 如果key已过期则删除，使用reader的时间
+func (this *Blackboard) _expireIfDue(key scopedKey, reader *Blackboard) {
+	if len(this._deadlines) == 0 {
+		return
+	}
+	deadline, ok := this._deadlines[key]
+	if ok && !reader.GetClock().Now().Before(deadline) {
+		this._expire(key)
+	}
+}
+
+func (this *Blackboard) _expire(key scopedKey) {
+	delete(this._deadlines, key)
+	memory := this._getMemory(key.treeScope, key.nodeScope)
+	old, ok := memory._memory[key.key]
+	if !ok {
+		return
+	}
+	memory.Remove(key.key)
+	this._observers.notify(BlackboardChange{Key: key.key, TreeScope: key.treeScope, NodeScope: key.nodeScope,
+		Old: old, Removed: true, Expired: true})
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+/**
+ * Typed accessors for the blackboard.
+ *
+ * Values written by the editor (node properties) or decoded from JSON are
+ * always float64, while values written by code are usually int, int64...
+ * The helpers below read a value as the requested type and convert between
+ * all numeric kinds, as long as the value fits in the target type.
+ *
+ *     Set(bb, "hp", 100)                      // global memory
+ *     hp := Get[int64](bb, "hp")              // 100
+ *     pos, ok := TryGet[Vec2](bb, "pos", tree.GetID())
+ *
+ * The optional scope arguments follow `Blackboard.Get`: the first one is the
+ * tree scope, the second one the node scope.
+**/
+
+// Number is the set of types the numeric conversion can produce.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// splitScope 将可变参数拆分为treeScope和nodeScope
+func splitScope(scope []string) (treeScope, nodeScope string) {
+	if len(scope) > 0 {
+		treeScope = scope[0]
+	}
+	if len(scope) > 1 {
+		nodeScope = scope[1]
+	}
+	return
+}
+
+// Set stores a typed value in the blackboard.
+func Set[T any](bb *Blackboard, key string, value T, scope ...string) {
+	treeScope, nodeScope := splitScope(scope)
+	bb.Set(key, value, treeScope, nodeScope)
+}
+
+// TryGet retrieves a value as T. Numeric values are converted to T when T is
+// a numeric type and the value fits. It returns false if the key is absent
+// or the value can't be represented as T.
+func TryGet[T any](bb *Blackboard, key string, scope ...string) (T, bool) {
+	treeScope, nodeScope := splitScope(scope)
+	return convertTo[T](bb.Get(key, treeScope, nodeScope))
+}
+
+// Get retrieves a value as T, returning the zero value of T when the key is
+// absent or the value can't be represented as T.
+func Get[T any](bb *Blackboard, key string, scope ...string) T {
+	v, _ := TryGet[T](bb, key, scope...)
+	return v
+}
+
+// convertTo 将任意值转换为T，数值类型之间可以互相转换
+func convertTo[T any](v interface{}) (T, bool) {
+	var zero T
+	if v == nil {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	to := reflect.TypeOf(&zero).Elem()
+	rv, ok := convertNumber(reflect.ValueOf(v), to)
+	if !ok {
+		return zero, false
+	}
+	return rv.Interface().(T), true
+}
+
+// ConvertNumber converts any numeric value to T. It returns false if v is not
+// a number, or if the conversion would overflow or drop a fractional part.
+func ConvertNumber[T Number](v interface{}) (T, bool) {
+	return convertTo[T](v)
+}
+
+// ReadNumber converts any numeric value to T and panics if it can't.
+func ReadNumber[T Number](v interface{}) T {
+	ret, ok := ConvertNumber[T](v)
+	if !ok {
+		var zero T
+		panic(fmt.Sprintf("错误的类型转成%v %v:%+v", reflect.TypeOf(zero), reflect.TypeOf(v), v))
+	}
+	return ret
+}
+
+// wrapNumber 不检查范围的数值转换
+func wrapNumber[T Number](v interface{}) T {
+	var zero T
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !isNumberKind(rv.Kind()) {
+		panic(fmt.Sprintf("错误的类型转成%v %v:%+v", reflect.TypeOf(zero), reflect.TypeOf(v), v))
+	}
+	return rv.Convert(reflect.TypeOf(zero)).Interface().(T)
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || isFloatKind(k)
+}
+
+// convertNumber 检查范围后进行数值转换，溢出或丢失小数部分时返回false
+func convertNumber(v reflect.Value, to reflect.Type) (reflect.Value, bool) {
+	from := v.Kind()
+	if !isNumberKind(from) || !isNumberKind(to.Kind()) {
+		return reflect.Value{}, false
+	}
+	out := reflect.New(to).Elem()
+	switch {
+	case isIntKind(from):
+		i := v.Int()
+		switch {
+		case isIntKind(to.Kind()):
+			if out.OverflowInt(i) {
+				return reflect.Value{}, false
+			}
+			out.SetInt(i)
+		case isUintKind(to.Kind()):
+			if i < 0 || out.OverflowUint(uint64(i)) {
+				return reflect.Value{}, false
+			}
+			out.SetUint(uint64(i))
+		default:
+			out.SetFloat(float64(i))
+		}
+	case isUintKind(from):
+		u := v.Uint()
+		switch {
+		case isIntKind(to.Kind()):
+			if u > math.MaxInt64 || out.OverflowInt(int64(u)) {
+				return reflect.Value{}, false
+			}
+			out.SetInt(int64(u))
+		case isUintKind(to.Kind()):
+			if out.OverflowUint(u) {
+				return reflect.Value{}, false
+			}
+			out.SetUint(u)
+		default:
+			out.SetFloat(float64(u))
+		}
+	default:
+		f := v.Float()
+		switch {
+		case isIntKind(to.Kind()):
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || out.OverflowInt(int64(f)) {
+				return reflect.Value{}, false
+			}
+			out.SetInt(int64(f))
+		case isUintKind(to.Kind()):
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || out.OverflowUint(uint64(f)) {
+				return reflect.Value{}, false
+			}
+			out.SetUint(uint64(f))
+		default:
+			if out.OverflowFloat(f) {
+				return reflect.Value{}, false
+			}
+			out.SetFloat(f)
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+/**
+ * Blackboard change observers.
+ *
+ * `Watch` registers a callback for one key in one context (the scopes follow
+ * `Blackboard.Set`), `WatchAll` registers a callback for every change. They
+ * fire for `Set`, `SetMem`, `SetTree` and `Remove`; writing the same
+ * comparable value again doesn't fire.
+ *
+ *     id := bb.Watch("hp", "", "", func(old, new interface{}) {
+ *       ui.UpdateHP(new)
+ *     })
+ *     ...
+ *     bb.Unwatch(id)
+ *
+ * Observers may write to the blackboard themselves: changes made while
+ * observers are running are queued and dispatched after the current change,
+ * in order, so observers never run re-entrantly.
+**/
+
+// BlackboardChange describes one change of a blackboard value.
+type BlackboardChange struct {
+	Key       string
+	TreeScope string
+	NodeScope string
+	Old       interface{}
+	New       interface{}
+	// Removed is true when the key was removed; New is then nil.
+	Removed bool
+	// Expired is true when the key was removed because its TTL elapsed.
+	Expired bool
+}
+
+// WatchID identifies a registered observer, for `Unwatch`.
+type WatchID int
+
+type scopedKey struct {
+	key, treeScope, nodeScope string
+}
+
+type watcher struct {
+	id  WatchID
+	key scopedKey
+	all bool
+	// 只接收过期事件
+	expired bool
+	fn      func(change BlackboardChange)
+}
+
+type observers struct {
+	nextID    WatchID
+	watchers  []*watcher
+	notifying bool
+	queue     []BlackboardChange
+}
+
+func newObservers() *observers {
+	return &observers{}
+}
+
+/**
+ * Watch registers an observer for one key. It is called with the old and
+ * new value each time the key changes in the given context.
+ *
+ * @param {String} key The key to watch.
+ * @param {String} treeScope The tree id, empty for the global memory.
+ * @param {String} nodeScope The node id, empty for the tree memory.
+ * @return {WatchID} The id to pass to `Unwatch`.
+**/
+func (this *Blackboard) Watch(key, treeScope, nodeScope string, fn func(old, new interface{})) WatchID {
+	if len(treeScope) == 0 {
+		nodeScope = ""
+	}
+	nodeScope = this._nodeKey(treeScope, nodeScope)
+	return this._observers.add(&watcher{
+		key: scopedKey{key, treeScope, nodeScope},
+		fn: func(change BlackboardChange) {
+			fn(change.Old, change.New)
+		},
+	})
+}
+
+// WatchAll registers an observer for every change of the blackboard.
+func (this *Blackboard) WatchAll(fn func(change BlackboardChange)) WatchID {
+	return this._observers.add(&watcher{all: true, fn: fn})
+}
+
+// Unwatch removes an observer registered with `Watch` or `WatchAll`.
+func (this *Blackboard) Unwatch(id WatchID) {
+	this._observers.remove(id)
+}
+
+func (this *observers) add(w *watcher) WatchID {
+	this.nextID++
+	w.id = this.nextID
+	this.watchers = append(this.watchers, w)
+	return w.id
+}
+
+func (this *observers) remove(id WatchID) {
+	for i, w := range this.watchers {
+		if w.id == id {
+			// 复制一份，避免影响正在分发的列表
+			watchers := make([]*watcher, 0, len(this.watchers)-1)
+			watchers = append(watchers, this.watchers[:i]...)
+			this.watchers = append(watchers, this.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (this *observers) active(id WatchID) bool {
+	for _, w := range this.watchers {
+		if w.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// notify 分发变化；observer内部产生的变化会排队，在当前变化分发完后依次分发
+func (this *observers) notify(change BlackboardChange) {
+	if this == nil || len(this.watchers) == 0 {
+		return
+	}
+	this.queue = append(this.queue, change)
+	if this.notifying {
+		return
+	}
+	this.notifying = true
+	defer func() {
+		this.notifying = false
+		this.queue = nil
+	}()
+	for len(this.queue) > 0 {
+		change := this.queue[0]
+		this.queue = this.queue[1:]
+		key := scopedKey{change.Key, change.TreeScope, change.NodeScope}
+		for _, w := range this.watchers {
+			if w.expired && !change.Expired {
+				continue
+			}
+			if (w.all || w.key == key) && this.active(w.id) {
+				w.fn(change)
+			}
+		}
+	}
+}
+
+// sameValue 判断两个值是否相同，不可比较的值总是视为不同
+func sameValue(a, b interface{}) (same bool) {
+	defer func() {
+		// 含有不可比较字段的结构比较时会panic
+		if recover() != nil {
+			same = false
+		}
+	}()
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// EncodeValue encodes a blackboard value as JSON, for the observers that
+// store or send the changes. A value that can't be encoded is encoded as
+// its `%v` string.
+func EncodeValue(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%v", v))
+	}
+	return data
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedGetCoercion(t *testing.T) {
+	bb := NewBlackboard()
+
+	var props map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"hp":100,"speed":1.5,"name":"npc"}`), &props); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range props {
+		bb.SetMem(k, v)
+	}
+
+	if v := bb.GetInt("hp", "", ""); v != 100 {
+		t.Errorf("GetInt = %d, want 100", v)
+	}
+	if v := Get[uint8](bb, "hp"); v != 100 {
+		t.Errorf("Get[uint8] = %d, want 100", v)
+	}
+	if _, ok := TryGet[int](bb, "speed"); ok {
+		t.Error("TryGet[int] of 1.5 should fail")
+	}
+	if v, ok := TryGet[float32](bb, "speed"); !ok || v != 1.5 {
+		t.Errorf("TryGet[float32] = %v,%v, want 1.5,true", v, ok)
+	}
+	if _, ok := TryGet[int](bb, "name"); ok {
+		t.Error("TryGet[int] of a string should fail")
+	}
+	if _, ok := TryGet[int](bb, "missing"); ok {
+		t.Error("TryGet of a missing key should fail")
+	}
+
+	Set(bb, "count", int64(-1), "tree", "node")
+	if v := Get[int](bb, "count", "tree", "node"); v != -1 {
+		t.Errorf("Get[int] scoped = %d, want -1", v)
+	}
+	if _, ok := TryGet[uint32](bb, "count", "tree", "node"); ok {
+		t.Error("negative value should not convert to uint32")
+	}
+	if _, ok := ConvertNumber[int8](300); ok {
+		t.Error("300 should overflow int8")
+	}
+}
+
+func TestReadNumberWraps(t *testing.T) {
+	// 旧的ReadNumberTo*不检查范围
+	if v := ReadNumberToUInt64(int64(-1)); v != 1<<64-1 {
+		t.Errorf("ReadNumberToUInt64(-1) = %d", v)
+	}
+	if v := ReadNumberToInt64(uint64(1<<64 - 1)); v != -1 {
+		t.Errorf("ReadNumberToInt64(MaxUint64) = %d", v)
+	}
+	if v := ReadNumberToInt32(int64(1 << 32)); v != 0 {
+		t.Errorf("ReadNumberToInt32(1<<32) = %d", v)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("ReadNumber[uint64](-1) should panic")
+		}
+	}()
+	ReadNumber[uint64](int64(-1))
+}
+
+func TestWatch(t *testing.T) {
+	bb := NewBlackboard()
+
+	var got []string
+	bb.Watch("hp", "", "", func(old, new interface{}) {
+		got = append(got, fmt.Sprint("hp:", old, "->", new))
+		// observers may write, the change is dispatched afterwards
+		bb.SetMem("dead", new == 0)
+	})
+	all := bb.WatchAll(func(change BlackboardChange) {
+		got = append(got, fmt.Sprint("all:", change.Key, " removed:", change.Removed))
+	})
+
+	bb.SetMem("hp", 10)
+	bb.SetMem("hp", 10) // same value, no event
+	bb.Set("hp", 5, "tree", "node")
+	bb.SetTree("hp", 5, "tree")
+	bb.RemoveTree("hp", "tree")
+	bb.RemoveTree("hp", "tree") // already removed, no event
+	bb.Unwatch(all)
+	bb.Remove("hp")
+
+	want := []string{
+		"hp:<nil>->10",
+		"all:hp removed:false",
+		"all:dead removed:false",
+		"all:hp removed:false",
+		"all:hp removed:false",
+		"all:hp removed:true",
+		"hp:10-><nil>",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestChildBlackboard(t *testing.T) {
+	team := NewBlackboard()
+	team.MarkShared("enemy")
+	team.SetMem("rally", "gate")
+
+	a := NewChildBlackboard(team)
+	b := NewChildBlackboard(team)
+
+	a.SetMem("hp", 10)
+	a.SetMem("enemy", 42)
+	b.SetMem("rally", "tower") // shadows the team value for b only
+
+	if v := b.GetMem("enemy"); v != 42 {
+		t.Errorf("shared key seen by sibling = %v, want 42", v)
+	}
+	if v := b.GetMem("hp"); v != nil {
+		t.Errorf("local key leaked to sibling: %v", v)
+	}
+	if v := a.GetMem("rally"); v != "gate" {
+		t.Errorf("fall-through read = %v, want gate", v)
+	}
+	if v := b.GetMem("rally"); v != "tower" {
+		t.Errorf("local read = %v, want tower", v)
+	}
+	if v := b.GetShared("rally"); v != "gate" {
+		t.Errorf("GetShared = %v, want gate", v)
+	}
+	if v := a.Get("enemy", "tree", ""); v != nil {
+		t.Errorf("tree memory should stay local, got %v", v)
+	}
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestSetWithTTL(t *testing.T) {
+	clock := &testClock{time.Unix(1000, 0)}
+	bb := NewBlackboard()
+	bb.SetClock(clock)
+
+	var expired []string
+	bb.OnExpired(func(change BlackboardChange) {
+		expired = append(expired, change.Key)
+	})
+
+	bb.SetWithTTL("seen", "enemy", time.Second)
+	bb.SetWithTTL("heard", "noise", 2*time.Second, "tree", "node")
+	bb.SetWithTTL("kept", 1, time.Second)
+	bb.SetMem("kept", 2) // a plain write clears the ttl
+
+	clock.now = clock.now.Add(time.Second)
+	if v := bb.GetMem("seen"); v != nil {
+		t.Errorf("expired key read as %v", v)
+	}
+	if v := bb.Get("heard", "tree", "node"); v != "noise" {
+		t.Errorf("live key read as %v", v)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	bb.PurgeExpired()
+	if v := bb.GetMem("kept"); v != 2 {
+		t.Errorf("rewritten key read as %v", v)
+	}
+	if strings.Join(expired, ",") != "seen,heard" {
+		t.Errorf("expired events = %v", expired)
+	}
+}
+
+func TestHaltDropsNodeTTL(t *testing.T) {
+	clock := &testClock{time.Unix(1000, 0)}
+	bb := NewBlackboard()
+	bb.SetClock(clock)
+	var expired []string
+	bb.OnExpired(func(change BlackboardChange) {
+		expired = append(expired, change.Key)
+	})
+
+	tree := keyTree(t, "t0")
+	bb.SetWithTTL("node", 1, time.Second, tree.GetID(), "root")
+	bb.SetWithTTL("tree", 2, time.Second, tree.GetID())
+	tree.Halt(bb)
+	if _, ok := bb.ExpiresAt("node", tree.GetID(), "root"); ok {
+		t.Error("the ttl of a node key survived Halt")
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	bb.PurgeExpired()
+	// node内存已被Halt丢弃，它的key不再过期
+	if strings.Join(expired, ",") != "tree" {
+		t.Errorf("expired events = %v, want tree", expired)
+	}
+}
+
+func TestRemap(t *testing.T) {
+	bb := NewBlackboard()
+	bb.SetMem("enemy", 7)
+	bb.SetMem("global", "g")
+
+	view := bb.Remap(map[string]interface{}{"target": "$enemy", "speed": 3, "label": "$$raw"})
+	if v := view.GetMem("target"); v != 7 {
+		t.Errorf("mapped read = %v, want 7", v)
+	}
+	if v := view.GetInt("speed", "", ""); v != 3 {
+		t.Errorf("constant read = %v, want 3", v)
+	}
+	if v := view.GetMem("label"); v != "$raw" {
+		t.Errorf("escaped constant = %v, want $raw", v)
+	}
+	if v := view.GetMem("global"); v != "g" {
+		t.Errorf("unmapped read = %v, want g", v)
+	}
+
+	view.SetMem("target", 8)
+	view.SetMem("speed", 100)
+	if v := bb.GetMem("enemy"); v != 8 {
+		t.Errorf("mapped write = %v, want 8", v)
+	}
+	if v := view.GetMem("speed"); v != 3 {
+		t.Errorf("constant port was overwritten: %v", v)
+	}
+
+	nested := view.Remap(map[string]interface{}{"goal": "$target"})
+	if v := nested.GetMem("goal"); v != 8 {
+		t.Errorf("nested read = %v, want 8", v)
+	}
+}
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"time"
+)
+
+// Clock is the time source of a tree. Nodes should use `Tick.Now` instead of
+// `time.Now`, so tests and replays can control time with `SetClock`.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the default clock, backed by time.Now.
+var SystemClock Clock = systemClock{}
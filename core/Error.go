@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	b3 "behavior3go"
+)
+
+/**
+ * Errors attached to the `b3.ERROR` status.
+ *
+ * A node reports why it failed by returning `tick.Fail(err)` instead of a
+ * bare `b3.ERROR`:
+ *
+ *     if target == nil {
+ *       return tick.Fail(fmt.Errorf("no target in range %v", this.radius))
+ *     }
+ *
+ * The error is recorded in the tick with the path of the node, and exposed
+ * by `BehaviorTree.TickWithResult`. Panics recovered by a `PanicPolicy` are
+ * recorded the same way, with a *NodePanic as error.
+**/
+
+// ErrNoChild is reported by decorators ticked without a child.
+var ErrNoChild = errors.New("decorator has no child")
+
+// NodeInfo identifies a node in a path of the tree.
+type NodeInfo struct {
+	ID       string
+	Name     string
+	Title    string
+	Category string
+	// IsSubTree is true for a `tree` node: the nodes after it in the path
+	// belong to the called subtree.
+	IsSubTree bool
+}
+
+func (this NodeInfo) String() string {
+	return fmt.Sprintf("%s(%s)", this.Title, this.ID)
+}
+
+func newNodeInfo(node IBaseNode) NodeInfo {
+	outer := getBaseNode(node).outerNode()
+	_, isSubTree := outer.(*SubTree)
+	category := outer.GetCategory()
+	if isSubTree {
+		category = "tree"
+	}
+	return NodeInfo{outer.GetID(), outer.GetName(), outer.GetTitle(), category, isSubTree}
+}
+
+// NodeError is an error reported by a node during a tick.
+type NodeError struct {
+	Node IBaseNode
+	// Path from the root of the ticked tree to the node.
+	Path []NodeInfo
+	Err  error
+}
+
+func (this *NodeError) Error() string {
+	path := make([]string, len(this.Path))
+	for i, info := range this.Path {
+		path[i] = info.String()
+	}
+	return fmt.Sprintf("%s: %v", strings.Join(path, "/"), this.Err)
+}
+
+func (this *NodeError) Unwrap() error {
+	return this.Err
+}
+
+// Fail records err for the node being executed and returns `b3.ERROR`.
+func (this *Tick) Fail(err error) b3.Status {
+	node := this.currentNode()
+	if node == nil {
+		return b3.ERROR
+	}
+	this._fail(node, err)
+	return b3.ERROR
+}
+
+// Errors returns the errors recorded during the tick, in order.
+func (this *Tick) Errors() []*NodeError {
+	return this._errors
+}
+
+func (this *Tick) _fail(node *BaseNode, err error) {
+	this._errors = append(this._errors, &NodeError{node.outerNode(), this._stackPath(node), err})
+}
+
+// _stackPath 当前执行栈到node的路径
+func (this *Tick) _stackPath(node *BaseNode) []NodeInfo {
+	path := make([]NodeInfo, 0, len(this._nodeStack)+1)
+	for _, n := range this._nodeStack {
+		path = append(path, newNodeInfo(n))
+		if n == node {
+			return path
+		}
+	}
+	// 节点已不在执行栈上(例如panic后恢复了栈)
+	return append(path, newNodeInfo(node))
+}
+
+//------------------------TickResult-------------------------
+
+// TickResult is the outcome of `BehaviorTree.TickWithResult`.
+type TickResult struct {
+	Status b3.Status
+	// Err is the first error reported during the tick, nil if none. It is
+	// a *NodeError.
+	Err error
+	// Errors lists every error reported during the tick.
+	Errors []*NodeError
+
+	// Running is the deepest node left RUNNING, nil if none.
+	Running IBaseNode
+	// RunningPath is the chain of RUNNING nodes, from the root to Running.
+	RunningPath []NodeInfo
+	// NodeCount is the number of nodes entered during the tick.
+	NodeCount int
+	// Trace lists every node executed during the tick, in order, when the
+	// tick was run `WithTrace`.
+	Trace []TraceEntry
+}
+
+// TickOption configures `BehaviorTree.TickWithResult`.
+type TickOption func(*tickOptions)
+
+type tickOptions struct {
+	trace bool
+}
+
+// WithTrace records the status of every node executed in `TickResult.Trace`.
+func WithTrace() TickOption {
+	return func(opts *tickOptions) {
+		opts.trace = true
+	}
+}
+
+/**
+ * TickWithResult works as `Tick`, and returns what happened during the
+ * tick: the errors reported by the nodes with `Tick.Fail` (or recovered
+ * panics) with the path of the node that produced each of them, the
+ * running node chain, the number of nodes entered and, `WithTrace`, the
+ * status of every node executed.
+**/
+func (this *BehaviorTree) TickWithResult(target interface{}, blackboard *Blackboard, opts ...TickOption) *TickResult {
+	var options tickOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var extra []TickListener
+	var trace *traceListener
+	if options.trace {
+		trace = &traceListener{}
+		extra = append(extra, trace)
+	}
+
+	tick, status := this._run(target, blackboard, extra)
+
+	result := &TickResult{Status: status, Errors: tick._errors, NodeCount: tick._nodeCount}
+	if len(tick._errors) > 0 {
+		result.Err = tick._errors[0]
+	}
+	openNodes := blackboard._getTreeData(this.id).OpenNodes
+	result.RunningPath = nodeInfoPath(openNodes)
+	if len(openNodes) > 0 {
+		result.Running = getBaseNode(openNodes[len(openNodes)-1]).outerNode()
+	}
+	if trace != nil {
+		result.Trace = trace.entries
+	}
+	return result
+}
+
+func nodeInfoPath(nodes []IBaseNode) []NodeInfo {
+	path := make([]NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		path = append(path, newNodeInfo(node))
+	}
+	return path
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	b3 "behavior3go"
+	"behavior3go/config"
+)
+
+// 自动布局：根节点在左，子节点向右展开，叶子节点从上到下排列
+const (
+	layoutDepth = 240.0 // 相邻深度的x间距
+	layoutLeaf  = 80.0  // 相邻叶子的y间距
+)
+
+/**
+ * Export returns the current structure of the tree as the editor exports
+ * it, so it can be saved and opened in the behavior3 editor: the nodes
+ * reachable from the root with their children, titles and properties,
+ * including the children added and the titles changed after the load.
+ *
+ * The nodes get `display` coordinates from an automatic layout, the root
+ * on the left and the leaves one below the other. The tree keeps the ID
+ * of the config it was loaded from, the ID called by `tree` nodes; nodes
+ * without ID, or sharing the ID of another node, get a new one.
+**/
+func (this *BehaviorTree) Export() *config.BTTreeCfg {
+	cfg := &config.BTTreeCfg{
+		Version:     config.EditorVersion,
+		Scope:       "tree",
+		ID:          this.cfgID,
+		Title:       this.title,
+		Description: this.description,
+		Properties:  copyProperties(this.properties),
+		Nodes:       make(map[string]config.BTNodeCfg),
+		Display:     &config.TreeDisplayCfg{CameraX: layoutDepth, CameraY: 4 * layoutLeaf, CameraZ: 1},
+	}
+	if cfg.ID == "" {
+		cfg.ID = this.id
+	}
+	if this.root == nil {
+		return cfg
+	}
+	e := &exporter{cfg: cfg, ids: make(map[IBaseNode]string), used: make(map[string]bool)}
+	cfg.Root = e.node(this.root, 1)
+	// 根节点和树的根在同一高度
+	rootY := cfg.Nodes[cfg.Root].Display.Y
+	for _, node := range cfg.Nodes {
+		node.Display.Y -= rootY
+	}
+	return cfg
+}
+
+// exporter 导出一棵树的节点
+type exporter struct {
+	cfg *config.BTTreeCfg
+	// 已导出的节点和它们的ID
+	ids  map[IBaseNode]string
+	used map[string]bool
+	// 下一个叶子的y
+	nextY float64
+}
+
+// node 导出节点和它的子节点，返回节点ID
+func (this *exporter) node(node IBaseNode, depth int) string {
+	if id, ok := this.ids[node]; ok {
+		return id
+	}
+	base := getBaseNode(node)
+	id := base.id
+	if id == "" || this.used[id] {
+		id = b3.CreateUUID()
+	}
+	this.ids[node] = id
+	this.used[id] = true
+
+	nodeCfg := config.BTNodeCfg{
+		Id:          id,
+		Name:        base.name,
+		Category:    base.category,
+		Title:       base.title,
+		Description: base.description,
+		Properties:  copyProperties(base.properties),
+	}
+	if _, ok := node.(*SubTree); ok {
+		nodeCfg.Category = "tree"
+	}
+
+	var children []IBaseNode
+	switch node.GetCategory() {
+	case b3.COMPOSITE:
+		comp := node.(IComposite)
+		for i := 0; i < comp.GetChildCount(); i++ {
+			children = append(children, comp.GetChild(i))
+		}
+	case b3.DECORATOR:
+		children = append(children, node.(IDecorator).GetChild())
+	}
+	// 父节点在第一个和最后一个子节点的中间
+	var first, last *config.DisplayCfg
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		cid := this.node(child, depth+1)
+		if node.GetCategory() == b3.DECORATOR {
+			nodeCfg.Child = cid
+		} else {
+			nodeCfg.Children = append(nodeCfg.Children, cid)
+		}
+		// 共享的节点只在第一次出现的位置
+		if display := this.cfg.Nodes[cid].Display; display != nil {
+			if first == nil {
+				first = display
+			}
+			last = display
+		}
+	}
+	nodeCfg.Display = &config.DisplayCfg{X: float64(depth) * layoutDepth}
+	if first != nil {
+		nodeCfg.Display.Y = (first.Y + last.Y) / 2
+	} else {
+		nodeCfg.Display.Y = this.nextY
+		this.nextY += layoutLeaf
+	}
+	this.cfg.Nodes[id] = nodeCfg
+	return id
+}
+
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	b3 "behavior3go"
+)
+
+/**
+ * Interceptors wrap the execution of the nodes, to add logging, metrics,
+ * feature flags, breakpoints... to every node without changing the node
+ * types:
+ *
+ *     tree.Use(func(next core.ExecFunc) core.ExecFunc {
+ *       return func(tick *core.Tick, node core.IBaseNode) b3.Status {
+ *         if disabled[node.GetTitle()] {
+ *           return b3.FAILURE // the node is not executed
+ *         }
+ *         return next(tick, node)
+ *       }
+ *     })
+ *
+ * `next` runs the whole lifecycle of the node (enter, open, tick, close,
+ * exit) and the panic recovery of the `PanicPolicy`. The interceptors
+ * registered with `UseInterceptor` run before those of the tree, in the
+ * order of registration; the nodes of the subtrees run with the
+ * interceptors of the ticked tree. Halting a node (see
+ * `BehaviorTree.Halt`) is not intercepted.
+ *
+ * Interceptors must be registered before ticking the tree, they are not
+ * safe for concurrent use with the ticks.
+**/
+
+// ExecFunc executes a node during a tick and returns its status.
+type ExecFunc func(tick *Tick, node IBaseNode) b3.Status
+
+// Interceptor wraps an ExecFunc.
+type Interceptor func(next ExecFunc) ExecFunc
+
+var globalInterceptors []Interceptor
+
+// UseInterceptor registers interceptors for every tree.
+func UseInterceptor(interceptors ...Interceptor) {
+	globalInterceptors = append(globalInterceptors[:len(globalInterceptors):len(globalInterceptors)], interceptors...)
+}
+
+// ClearInterceptors removes the interceptors registered with UseInterceptor.
+func ClearInterceptors() {
+	globalInterceptors = nil
+}
+
+// Use registers interceptors for the tree.
+func (this *BehaviorTree) Use(interceptors ...Interceptor) {
+	this.interceptors = append(this.interceptors[:len(this.interceptors):len(this.interceptors)], interceptors...)
+}
+
+// execute 拦截器链的最内层：节点的生命周期
+func execute(tick *Tick, node IBaseNode) b3.Status {
+	return getBaseNode(node)._lifecycle(tick)
+}
+
+// _execFunc 返回本次tick的拦截器链，没有拦截器时返回nil。第一次调用时构建
+func (this *Tick) _execFunc() ExecFunc {
+	if !this._execReady {
+		this._execReady = true
+		var interceptors []Interceptor
+		interceptors = append(interceptors, globalInterceptors...)
+		if this.tree != nil {
+			interceptors = append(interceptors, this.tree.interceptors...)
+		}
+		if len(interceptors) > 0 {
+			exec := ExecFunc(execute)
+			for i := len(interceptors) - 1; i >= 0; i-- {
+				exec = interceptors[i](exec)
+			}
+			this._exec = exec
+		}
+	}
+	return this._exec
+}
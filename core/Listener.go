@@ -0,0 +1,206 @@
+package core
+
+import (
+	"sync"
+
+	b3 "behavior3go"
+)
+
+/**
+ * Tick listeners.
+ *
+ * A listener added to a tree with `AddListener` (or given to `SetDebug`) is
+ * called back during every tick of the tree, for every node:
+ *
+ *     TickStart
+ *       EnterNode -> OpenNode (if not open) -> TickNode -> ...children...
+ *         -> TickedNode(status) -> CloseNode (if not RUNNING) -> ExitNode
+ *     TickEnd(status)
+ *
+ * `HaltNode` is called before `CloseNode` when a RUNNING node is aborted.
+ * `BehaviorTree.Halt` calls `HaltNode` and `CloseNode` outside of
+ * `TickStart`/`TickEnd`. When a `PanicPolicy` recovers a panic, the
+ * panicking node gets `TickedNode` with `b3.ERROR` and the nodes unwound by
+ * the panic get `ExitNode`. When a panic is not recovered, the unwound
+ * nodes get no callback, and `TickEnd` is called with `b3.ERROR` before the
+ * panic leaves `Tick`.
+ *
+ * The node passed is the node loaded in the tree (the custom node type).
+ * Trees are shared by many agents: use `tick.Blackboard` to tell the agents
+ * apart. Embed `BaseTickListener` to implement only some callbacks.
+**/
+type TickListener interface {
+	TickStart(tick *Tick)
+	TickEnd(tick *Tick, status b3.Status)
+	EnterNode(tick *Tick, node IBaseNode)
+	OpenNode(tick *Tick, node IBaseNode)
+	TickNode(tick *Tick, node IBaseNode)
+	TickedNode(tick *Tick, node IBaseNode, status b3.Status)
+	CloseNode(tick *Tick, node IBaseNode)
+	HaltNode(tick *Tick, node IBaseNode)
+	ExitNode(tick *Tick, node IBaseNode)
+}
+
+// BaseTickListener implements TickListener with empty callbacks.
+type BaseTickListener struct {
+}
+
+func (this *BaseTickListener) TickStart(tick *Tick)                                    {}
+func (this *BaseTickListener) TickEnd(tick *Tick, status b3.Status)                    {}
+func (this *BaseTickListener) EnterNode(tick *Tick, node IBaseNode)                    {}
+func (this *BaseTickListener) OpenNode(tick *Tick, node IBaseNode)                     {}
+func (this *BaseTickListener) TickNode(tick *Tick, node IBaseNode)                     {}
+func (this *BaseTickListener) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {}
+func (this *BaseTickListener) CloseNode(tick *Tick, node IBaseNode)                    {}
+func (this *BaseTickListener) HaltNode(tick *Tick, node IBaseNode)                     {}
+func (this *BaseTickListener) ExitNode(tick *Tick, node IBaseNode)                     {}
+
+// AddListener registers a listener called during every tick of the tree.
+// Listeners can be added and removed while other goroutines tick the tree;
+// the ticks already started keep the listeners they started with.
+func (this *BehaviorTree) AddListener(listener TickListener) {
+	this.listenersMu.Lock()
+	defer this.listenersMu.Unlock()
+	listeners := make([]TickListener, 0, len(this.listeners)+1)
+	listeners = append(listeners, this.listeners...)
+	this.listeners = append(listeners, listener)
+}
+
+// RemoveListener unregisters a listener added with `AddListener`.
+func (this *BehaviorTree) RemoveListener(listener TickListener) {
+	this.listenersMu.Lock()
+	defer this.listenersMu.Unlock()
+	for i, l := range this.listeners {
+		if l == listener {
+			listeners := make([]TickListener, 0, len(this.listeners)-1)
+			listeners = append(listeners, this.listeners[:i]...)
+			this.listeners = append(listeners, this.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// _tickListeners 本次tick的listener：树的listener，以及实现了TickListener的debug。
+// listeners只会被整体替换，返回的切片不会再被修改
+func (this *BehaviorTree) _tickListeners() []TickListener {
+	this.listenersMu.Lock()
+	listeners := this.listeners
+	this.listenersMu.Unlock()
+	if debug, ok := this.debug.(TickListener); ok {
+		listeners = append(listeners[:len(listeners):len(listeners)], debug)
+	}
+	return listeners
+}
+
+// AddListener registers a listener for the rest of this tick only.
+func (this *Tick) AddListener(listener TickListener) {
+	this._listeners = append(this._listeners[:len(this._listeners):len(this._listeners)], listener)
+}
+
+//------------------------TreeListeners-------------------------
+
+/**
+ * TreeListeners keeps one listener of type L per tree, for the observers
+ * attached to several trees (metrics, coverage, traces). It is safe for
+ * concurrent use, and the trees can be ticked while listeners are attached
+ * and detached. The zero value is ready to use.
+**/
+type TreeListeners[L TickListener] struct {
+	mu    sync.Mutex
+	trees map[*BehaviorTree]L
+	// 按Attach的顺序
+	order []*BehaviorTree
+}
+
+// Attach adds the listener returned by create to tree, and returns it. If
+// the tree is already attached, it returns its listener and false.
+func (this *TreeListeners[L]) Attach(tree *BehaviorTree, create func() L) (L, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if l, ok := this.trees[tree]; ok {
+		return l, false
+	}
+	if this.trees == nil {
+		this.trees = make(map[*BehaviorTree]L)
+	}
+	l := create()
+	this.trees[tree] = l
+	this.order = append(this.order, tree)
+	tree.AddListener(l)
+	return l, true
+}
+
+// Detach removes the listener of tree, and returns it.
+func (this *TreeListeners[L]) Detach(tree *BehaviorTree) (L, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	l, ok := this.trees[tree]
+	if !ok {
+		return l, false
+	}
+	tree.RemoveListener(l)
+	delete(this.trees, tree)
+	for i, t := range this.order {
+		if t == tree {
+			this.order = append(this.order[:i:i], this.order[i+1:]...)
+			break
+		}
+	}
+	return l, true
+}
+
+// DetachAll removes the listeners of every tree.
+func (this *TreeListeners[L]) DetachAll() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, tree := range this.order {
+		tree.RemoveListener(this.trees[tree])
+	}
+	this.trees = nil
+	this.order = nil
+}
+
+// Each calls fn for every attached tree, in the order of Attach. fn must
+// not attach or detach trees.
+func (this *TreeListeners[L]) Each(fn func(tree *BehaviorTree, listener L)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, tree := range this.order {
+		fn(tree, this.trees[tree])
+	}
+}
+
+//------------------------trace-------------------------
+
+// TraceEntry is the execution of one node during a tick.
+type TraceEntry struct {
+	Node NodeInfo
+	// Depth in the execution, the root is 0. Nodes of a subtree are deeper
+	// than the `tree` node calling it.
+	Depth  int
+	Status b3.Status
+}
+
+// traceListener 按进入顺序记录节点的执行，状态在节点tick完成后填入
+type traceListener struct {
+	BaseTickListener
+	entries []TraceEntry
+	stack   []int
+}
+
+func (this *traceListener) EnterNode(tick *Tick, node IBaseNode) {
+	this.stack = append(this.stack, len(this.entries))
+	this.entries = append(this.entries, TraceEntry{Node: newNodeInfo(node), Depth: len(this.stack) - 1})
+}
+
+func (this *traceListener) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	if len(this.stack) > 0 {
+		this.entries[this.stack[len(this.stack)-1]].Status = status
+	}
+}
+
+func (this *traceListener) ExitNode(tick *Tick, node IBaseNode) {
+	if len(this.stack) > 0 {
+		this.stack = this.stack[:len(this.stack)-1]
+	}
+}
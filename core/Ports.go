@@ -0,0 +1,276 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	. "behavior3go/config"
+)
+
+/**
+ * Node input/output ports.
+ *
+ * A node declares named ports by implementing `IPorts`, and the tree config
+ * maps each port to a blackboard key in the node properties, with the same
+ * `$key` syntax as the `SubTree` port mappings:
+ *
+ *     func (this *Chase) Ports() []core.Port {
+ *       return []core.Port{
+ *         core.InputPort[int64]("target").Require(),
+ *         core.InputPort[float64]("speed").WithDefault(1.0),
+ *         core.OutputPort[float64]("distance"),
+ *       }
+ *     }
+ *
+ *     // node properties in the editor
+ *     {"target": "$enemy", "speed": 3, "distance": "$enemyDistance"}
+ *
+ * The node then reads `tick.Input("target")` and writes
+ * `tick.Output("distance", d)` instead of hard-coding blackboard keys. A
+ * port can also be mapped to a constant (inputs only).
+ *
+ * `BehaviorTree.Load` checks the mappings: a required port must be mapped,
+ * an output must be mapped to a key, and a typed input constant must be
+ * convertible to the port type. `BehaviorTree.KeyReport` lists the keys
+ * each node reads and writes.
+**/
+
+// PortDirection tells whether a port is read, written or both.
+type PortDirection uint8
+
+const (
+	PortIn PortDirection = 1 << iota
+	PortOut
+	PortInOut = PortIn | PortOut
+)
+
+// Port describes one input or output of a node.
+type Port struct {
+	Name        string
+	Direction   PortDirection
+	Type        reflect.Type // nil accepts any value
+	Required    bool
+	Default     interface{} // mapping used when the config has none
+	Description string
+}
+
+func InputPort[T any](name string) Port {
+	return Port{Name: name, Direction: PortIn, Type: typeOf[T]()}
+}
+
+func OutputPort[T any](name string) Port {
+	return Port{Name: name, Direction: PortOut, Type: typeOf[T]()}
+}
+
+func InOutPort[T any](name string) Port {
+	return Port{Name: name, Direction: PortInOut, Type: typeOf[T]()}
+}
+
+// Require marks the port as mandatory in the tree config.
+func (p Port) Require() Port {
+	p.Required = true
+	return p
+}
+
+// WithDefault sets the mapping ("$key" or a constant) used when the tree
+// config doesn't map the port.
+func (p Port) WithDefault(mapping interface{}) Port {
+	p.Default = mapping
+	return p
+}
+
+// IPorts is implemented by nodes that declare ports.
+type IPorts interface {
+	Ports() []Port
+}
+
+// bindPorts 根据节点配置绑定端口映射，检查失败返回错误
+func bindPorts(node IBaseNode, cfg *BTNodeCfg) error {
+	declarer, ok := node.(IPorts)
+	if !ok {
+		return nil
+	}
+	base := getBaseNode(node)
+	base.ports = make(map[string]Port)
+	base.portMap = make(map[string]interface{})
+	for _, port := range declarer.Ports() {
+		base.ports[port.Name] = port
+		mapping, ok := cfg.Properties[port.Name]
+		if !ok || mapping == nil {
+			mapping = port.Default
+		}
+		if mapping == nil {
+			if port.Required {
+				return fmt.Errorf("node %s(%s) port %q is not mapped", cfg.Title, cfg.Id, port.Name)
+			}
+			continue
+		}
+		if _, isRef := PortRef(mapping); !isRef {
+			if port.Direction&PortOut != 0 {
+				return fmt.Errorf("node %s(%s) output port %q must be mapped to a $key, got %v", cfg.Title, cfg.Id, port.Name, mapping)
+			}
+			constant := portConst(mapping)
+			if port.Type != nil {
+				if _, ok := convertValue(constant, port.Type); !ok {
+					return fmt.Errorf("node %s(%s) port %q expects %v, got %v", cfg.Title, cfg.Id, port.Name, port.Type, mapping)
+				}
+			}
+		}
+		base.portMap[port.Name] = mapping
+	}
+	return nil
+}
+
+// convertValue 将值转换为类型t，规则与TryGet相同
+func convertValue(v interface{}, t reflect.Type) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return reflect.Value{}, false
+	}
+	if rv.Type().AssignableTo(t) {
+		return rv, true
+	}
+	return convertNumber(rv, t)
+}
+
+// getBaseNode 取得节点的BaseNode
+func getBaseNode(node IBaseNode) *BaseNode {
+	return node.(interface{ baseNode() *BaseNode }).baseNode()
+}
+
+//------------------------Tick-------------------------
+
+// TryInput reads an input port of the node being executed (or halted),
+// converted to the type of the port like `TryGet`. A value that can't be
+// converted reads as absent.
+func (this *Tick) TryInput(name string) (interface{}, bool) {
+	node := this.currentNode()
+	if node == nil {
+		return nil, false
+	}
+	mapping, ok := node.portMap[name]
+	if !ok {
+		return nil, false
+	}
+	var v interface{}
+	if key, isRef := PortRef(mapping); isRef {
+		v = this.Blackboard.GetMem(key)
+	} else {
+		v = portConst(mapping)
+	}
+	if v == nil {
+		return nil, false
+	}
+	if t := node.ports[name].Type; t != nil {
+		converted, ok := convertValue(v, t)
+		if !ok {
+			return nil, false
+		}
+		v = converted.Interface()
+	}
+	return v, true
+}
+
+// Input reads an input port of the node being executed, nil if the port is
+// not mapped, the key is absent or its value has not the port type.
+func (this *Tick) Input(name string) interface{} {
+	v, _ := this.TryInput(name)
+	return v
+}
+
+// Output writes an output port of the node being executed (or halted).
+// Writes to an unmapped optional port are dropped.
+func (this *Tick) Output(name string, value interface{}) {
+	node := this.currentNode()
+	if node == nil {
+		return
+	}
+	if key, isRef := PortRef(node.portMap[name]); isRef {
+		this.Blackboard.SetMem(key, value)
+	}
+}
+
+// GetInput reads an input port as T, converting numbers like `TryGet`.
+func GetInput[T any](tick *Tick, name string) (T, bool) {
+	v, ok := tick.TryInput(name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return convertTo[T](v)
+}
+
+//------------------------Report-------------------------
+
+// NodeKeyReport lists the blackboard keys used by one node.
+type NodeKeyReport struct {
+	NodeID string
+	Name   string
+	Title  string
+	Reads  []string
+	Writes []string
+}
+
+// nodeKeyUsages 收集节点使用的key：IBlackboardKeys声明的，以及端口映射的
+func nodeKeyUsages(node IBaseNode) []KeyUsage {
+	var usages []KeyUsage
+	if declarer, ok := node.(IBlackboardKeys); ok {
+		usages = append(usages, declarer.BlackboardKeys()...)
+	}
+	if _, ok := node.(IPorts); ok {
+		base := getBaseNode(node)
+		names := make([]string, 0, len(base.portMap))
+		for name := range base.portMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key, isRef := PortRef(base.portMap[name])
+			if !isRef {
+				continue
+			}
+			port := base.ports[name]
+			var access KeyAccess
+			if port.Direction&PortIn != 0 {
+				access |= KeyRead
+			}
+			if port.Direction&PortOut != 0 {
+				access |= KeyWrite
+			}
+			usages = append(usages, KeyUsage{key, port.Type, GlobalScope, access})
+		}
+	}
+	return usages
+}
+
+/**
+ * KeyReport lists, for each node of the tree that declares ports or
+ * implements `IBlackboardKeys`, the blackboard keys it reads and writes.
+ * Nodes are listed depth-first from the root.
+**/
+func (this *BehaviorTree) KeyReport() []NodeKeyReport {
+	var report []NodeKeyReport
+	this.Walk(func(node IBaseNode, depth int) bool {
+		usages := nodeKeyUsages(node)
+		if len(usages) == 0 {
+			return true
+		}
+		entry := NodeKeyReport{NodeID: node.GetID(), Name: node.GetName(), Title: node.GetTitle()}
+		for _, usage := range usages {
+			name := usage.Name
+			if usage.Scope != GlobalScope {
+				name = usage.Scope.String() + ":" + name
+			}
+			if usage.Access&KeyRead != 0 {
+				entry.Reads = append(entry.Reads, name)
+			}
+			if usage.Access&KeyWrite != 0 {
+				entry.Writes = append(entry.Writes, name)
+			}
+		}
+		report = append(report, entry)
+		return true
+	})
+	return report
+}
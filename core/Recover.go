@@ -0,0 +1,153 @@
+package core
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	b3 "behavior3go"
+)
+
+/**
+ * Per-node panic recovery.
+ *
+ * By default a panic in a node propagates out of `BehaviorTree.Tick`. With
+ * a `PanicPolicy`, set on a tree with `SetPanicPolicy` or for every tree
+ * with `SetDefaultPanicPolicy`, the panic is recovered in the node that
+ * raised it:
+ *
+ * - the node returns `b3.ERROR` to its parent and the tick goes on;
+ * - the panic value and stack are attached to the tick (`Tick.Panics`,
+ *   and `Tick.Errors` as a *NodeError);
+ * - the policy `Handler` is called, to log or report it;
+ * - with `DisableNode`, the node keeps returning `b3.ERROR` without running
+ *   for this agent, until `BehaviorTree.Halt` resets the node memory.
+ *
+ *     core.SetDefaultPanicPolicy(&core.PanicPolicy{
+ *       DisableNode: true,
+ *       Handler: func(tick *core.Tick, p *core.NodePanic) {
+ *         log.Println(p, string(p.Stack))
+ *       },
+ *     })
+**/
+
+// PanicPolicy configures the recovery of panicking nodes.
+type PanicPolicy struct {
+	// Disable the node for the agent (the blackboard) after a panic.
+	DisableNode bool
+	// Called with every recovered panic, may be nil.
+	Handler func(tick *Tick, p *NodePanic)
+}
+
+// NodePanic is a panic recovered in a node.
+type NodePanic struct {
+	Node  IBaseNode
+	Value interface{}
+	Stack []byte
+}
+
+func (this *NodePanic) Error() string {
+	return fmt.Sprintf("panic in node %s(%s): %v", this.Node.GetTitle(), this.Node.GetID(), this.Value)
+}
+
+var defaultPanicPolicy *PanicPolicy
+
+// SetDefaultPanicPolicy sets the policy of the trees without their own, nil
+// lets panics propagate.
+func SetDefaultPanicPolicy(policy *PanicPolicy) {
+	defaultPanicPolicy = policy
+}
+
+// SetPanicPolicy sets the panic policy of the tree, nil falls back to the
+// default policy.
+func (this *BehaviorTree) SetPanicPolicy(policy *PanicPolicy) {
+	this.panicPolicy = policy
+}
+
+func (this *BehaviorTree) GetPanicPolicy() *PanicPolicy {
+	if this.panicPolicy != nil {
+		return this.panicPolicy
+	}
+	return defaultPanicPolicy
+}
+
+// Panics returns the panics recovered during the tick.
+func (this *Tick) Panics() []*NodePanic {
+	return this._panics
+}
+
+// _panicPolicy 返回本次tick使用的policy，可能为nil
+func (this *Tick) _panicPolicy() *PanicPolicy {
+	if this.tree == nil {
+		return defaultPanicPolicy
+	}
+	return this.tree.GetPanicPolicy()
+}
+
+// 节点被禁用的标记，保存在节点内存中
+const panicDisabledKey = "panicDisabled"
+
+func (this *Tick) _isDisabled(node *BaseNode) bool {
+	return this.Blackboard.GetBool(panicDisabledKey, this.tree.id, node.id)
+}
+
+// tickState 记录进入节点时tick的状态，用于panic后恢复
+type tickState struct {
+	openNodes  int
+	nodeStack  int
+	subtrees   int
+	blackboard *Blackboard
+}
+
+func (this *Tick) _saveState() tickState {
+	return tickState{len(this._openNodes), len(this._nodeStack), len(this._openSubtreeNodes), this.Blackboard}
+}
+
+/**
+ * _recoverPanic 处理节点的panic：恢复tick状态，将节点及其open的子孙标记为close(不调用回调，
+ * 它们的状态已不可信)，记录panic并调用handler。返回节点的状态b3.ERROR。
+**/
+func (this *Tick) _recoverPanic(node *BaseNode, value interface{}, state tickState, policy *PanicPolicy) b3.Status {
+	p := &NodePanic{Node: node.outerNode(), Value: value, Stack: debug.Stack()}
+
+	// 通知listener：panic的节点返回ERROR，被展开的节点退出
+	if len(this._listeners) > 0 {
+		this._tickedNode(node, b3.ERROR)
+		for i := len(this._nodeStack) - 1; i >= state.nodeStack; i-- {
+			for _, l := range this._listeners {
+				l.ExitNode(this, this._nodeStack[i].outerNode())
+			}
+		}
+	}
+
+	if state.subtrees < len(this._openSubtreeNodes) {
+		this._openSubtreeNodes = this._openSubtreeNodes[:state.subtrees]
+	}
+	subtrees := this._openSubtreeNodes
+	if state.nodeStack < len(this._nodeStack) {
+		this._nodeStack = this._nodeStack[:state.nodeStack]
+	}
+	if state.openNodes < len(this._openNodes) {
+		opened := this._openNodes[state.openNodes:]
+		for i, open := range opened {
+			// 子树中的节点在子树的黑板视图中关闭
+			this.Blackboard = state.blackboard
+			this._openSubtreeNodes = append([]*SubTree(nil), subtrees...)
+			this._enterSubtrees(opened[:i])
+			this.Blackboard.Set("isOpen", false, this.tree.id, open.GetID())
+		}
+		this._openNodes = this._openNodes[:state.openNodes]
+	}
+	this.Blackboard = state.blackboard
+	this._openSubtreeNodes = subtrees
+	this.Blackboard.Set("isOpen", false, this.tree.id, node.id)
+
+	if policy.DisableNode {
+		this.Blackboard.Set(panicDisabledKey, true, this.tree.id, node.id)
+	}
+	this._panics = append(this._panics, p)
+	this._fail(node, p)
+	if policy.Handler != nil {
+		policy.Handler(this, p)
+	}
+	return b3.ERROR
+}
@@ -1,18 +1,38 @@
 package core
 
 import (
+	"fmt"
+	"strings"
+
 	b3 "behavior3go"
 	. "behavior3go/config"
 )
 
 //子树，通过Name关联树ID查找
+//节点的properties是子树的端口映射，例如 {"target": "$enemy", "speed": 3, "label": "$$raw"}：
+//子树中读写全局key "target" 实际访问调用方的 "enemy"，读取 "speed" 得到3，读取 "label" 得到"$raw"。
+//这样同一个子树可以被不同的参数复用，见 Blackboard.Remap。
+//字符串常量要用$$转义：不以$开头的字符串(如编辑器中的注释)只是节点的属性，不会遮住子树自己的key
 type SubTree struct {
 	Action
 	//tree *BehaviorTree
+	ports map[string]interface{}
 }
 
 func (this *SubTree) Initialize(setting *BTNodeCfg) {
 	this.Action.Initialize(setting)
+	this.ports = make(map[string]interface{})
+	for k, v := range setting.Properties {
+		if str, ok := v.(string); ok && !strings.HasPrefix(str, "$") {
+			continue
+		}
+		this.ports[k] = v
+	}
+}
+
+// GetPorts returns the port mapping of the subtree call.
+func (this *SubTree) GetPorts() map[string]interface{} {
+	return this.ports
 }
 /**
  *执行子树
@@ -24,24 +44,50 @@ func (this *SubTree) OnTick(tick *Tick) b3.Status {
 
 	//使用子树，必须先SetSubTreeLoadFunc
 	//子树可能没有加载上来，所以要延迟加载执行
+	if subTreeLoadFunc == nil {
+		return tick.Fail(fmt.Errorf("subtree %s: SetSubTreeLoadFunc was not called", this.GetName()))
+	}
 	sTree := subTreeLoadFunc(this.GetName())
 	if nil == sTree {
-		return b3.ERROR
+		return tick.Fail(fmt.Errorf("subtree %s not found", this.GetName()))
 	}
 
 	if tick.GetTarget() == nil {
-		panic("SubTree tick.GetTarget() nil !")
+		return tick.Fail(fmt.Errorf("subtree %s: the tick has no target", this.GetName()))
+	}
+	if sTree.GetRoot() == nil {
+		return tick.Fail(fmt.Errorf("subtree %s has no root", this.GetName()))
+	}
+	// 子树调用自己时会无限递归
+	if sTree == tick.GetTree() {
+		return tick.Fail(fmt.Errorf("subtree %s calls itself", this.GetName()))
+	}
+	for _, st := range tick._openSubtreeNodes {
+		if st.GetName() == this.GetName() {
+			return tick.Fail(fmt.Errorf("subtree %s calls itself", this.GetName()))
+		}
 	}
 
 	//tar := tick.GetTarget()
 	//return sTree.Tick(tar, tick.Blackboard)
 
-	tick.pushSubtreeNode(this)
+	blackboard := tick.Blackboard
+	defer func() {
+		tick.Blackboard = blackboard
+	}()
+
+	this._enter(tick)
 	ret := sTree.GetRoot().Execute(tick)
 	tick.popSubtreeNode()
 	return ret
 }
 
+// _enter 进入子树：子树在端口映射后的黑板视图上运行，节点内存按Tick.NodeKey区分
+func (this *SubTree) _enter(tick *Tick) {
+	tick.Blackboard = tick.Blackboard._subtreeView(this.ports, tick.NodeKey(this)+"/")
+	tick.pushSubtreeNode(this)
+}
+
 func (this *SubTree) String() string  {
 	return "SBT_"+this.GetTitle()
 }
@@ -52,4 +98,19 @@ var subTreeLoadFunc func(string) *BehaviorTree
 //获取子树的方法
 func SetSubTreeLoadFunc(f func(string) *BehaviorTree) {
 	subTreeLoadFunc = f
-}
\ No newline at end of file
+}
+
+// GetSubTreeLoadFunc returns the function set by SetSubTreeLoadFunc, nil if
+// none.
+func GetSubTreeLoadFunc() func(string) *BehaviorTree {
+	return subTreeLoadFunc
+}
+
+// GetSubTree returns the tree called by the `tree` nodes named name, nil if
+// it is not found or SetSubTreeLoadFunc was not called.
+func GetSubTree(name string) *BehaviorTree {
+	if subTreeLoadFunc == nil {
+		return nil
+	}
+	return subTreeLoadFunc(name)
+}
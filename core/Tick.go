@@ -2,6 +2,10 @@ package core
 
 import (
 	_ "fmt"
+	"strings"
+	"time"
+
+	b3 "behavior3go"
 )
 
 /**
@@ -50,6 +54,27 @@ type Tick struct {
 	// The number of nodes entered during the tick. Update during the tree
 	// traversal.
 	_nodeCount int
+
+	// The nodes being executed, from the root to the current node.
+	// push node on enter, pop node on exit.
+	_nodeStack []*BaseNode
+
+	// The panics recovered during the tick (see PanicPolicy).
+	_panics []*NodePanic
+
+	// The errors reported during the tick (see Tick.Fail).
+	_errors []*NodeError
+
+	// The listeners called back during the tick (see TickListener).
+	_listeners []TickListener
+
+	// The interceptor chain of the tick (see Interceptor), built on the
+	// first node execution.
+	_exec      ExecFunc
+	_execReady bool
+
+	// The values stored by the listeners and interceptors (see Tick.SetValue).
+	_values map[interface{}]interface{}
 }
 
 func NewTick() *Tick {
@@ -74,12 +99,42 @@ func (this *Tick) Initialize() {
 	this._openNodes = nil
 	this._openSubtreeNodes = nil
 	this._nodeCount = 0
+	this._nodeStack = nil
+	this._panics = nil
+	this._errors = nil
+	this._listeners = nil
+	this._exec = nil
+	this._execReady = false
+	this._values = nil
+}
+
+// SetValue stores a value for the rest of the tick. A tick runs on one
+// goroutine, so listeners and interceptors can keep their per-tick state
+// here without locking; use a key of your own type, like context keys.
+func (this *Tick) SetValue(key, value interface{}) {
+	if this._values == nil {
+		this._values = make(map[interface{}]interface{})
+	}
+	this._values[key] = value
+}
+
+// Value returns the value stored with SetValue, nil if none.
+func (this *Tick) Value(key interface{}) interface{} {
+	return this._values[key]
 }
 
 func (this *Tick) GetTree() *BehaviorTree {
 	return this.tree
 }
 
+// Now returns the current time of the tree clock.
+func (this *Tick) Now() time.Time {
+	if this.tree == nil {
+		return SystemClock.Now()
+	}
+	return this.tree.GetClock().Now()
+}
+
 /**
  * Called when entering a node (called by BaseNode).
  * @method _enterNode
@@ -89,8 +144,14 @@ func (this *Tick) GetTree() *BehaviorTree {
 func (this *Tick) _enterNode(node IBaseNode) {
 	this._nodeCount++
 	this._openNodes = append(this._openNodes, node)
+	this._nodeStack = append(this._nodeStack, getBaseNode(node))
 
-	// TODO: call debug here
+	if len(this._listeners) > 0 {
+		outer := getBaseNode(node).outerNode()
+		for _, l := range this._listeners {
+			l.EnterNode(this, outer)
+		}
+	}
 }
 
 /**
@@ -100,7 +161,9 @@ func (this *Tick) _enterNode(node IBaseNode) {
  * @protected
 **/
 func (this *Tick) _openNode(node *BaseNode) {
-	// TODO: call debug here
+	for _, l := range this._listeners {
+		l.OpenNode(this, node.outerNode())
+	}
 }
 
 /**
@@ -110,8 +173,23 @@ func (this *Tick) _openNode(node *BaseNode) {
  * @protected
 **/
 func (this *Tick) _tickNode(node *BaseNode) {
-	// TODO: call debug here
 	//fmt.Println("Tick _tickNode :", this.debug, " id:", node.GetID(), node.GetTitle())
+	for _, l := range this._listeners {
+		l.TickNode(this, node.outerNode())
+	}
+}
+
+/**
+ * Callback after ticking a node (called by BaseNode).
+ * @method _tickedNode
+ * @param {Object} node The node that called this method.
+ * @param {Constant} status The status returned by the node.
+ * @protected
+**/
+func (this *Tick) _tickedNode(node *BaseNode, status b3.Status) {
+	for _, l := range this._listeners {
+		l.TickedNode(this, node.outerNode(), status)
+	}
 }
 
 /**
@@ -121,13 +199,67 @@ func (this *Tick) _tickNode(node *BaseNode) {
  * @protected
 **/
 func (this *Tick) _closeNode(node *BaseNode) {
-	// TODO: call debug here
+	// 从open列表中移除该节点。
+	// 在它之后open的节点是它的子孙，父节点结束时它们仍是open状态(例如MaxTime超时)，需要先halt
+	for i := len(this._openNodes) - 1; i >= 0; i-- {
+		if this._openNodes[i] == IBaseNode(node) {
+			descendants := append([]IBaseNode(nil), this._openNodes[i+1:]...)
+			this._openNodes = this._openNodes[:i]
+			this._haltNodes(descendants, 0)
+			break
+		}
+	}
 
-	ulen := len(this._openNodes)
-	if ulen > 0 {
-		this._openNodes = this._openNodes[:ulen-1]
+	for _, l := range this._listeners {
+		l.CloseNode(this, node.outerNode())
 	}
+}
 
+/**
+ * Callback when halting a node (called by BaseNode).
+ * @method _haltNode
+ * @param {Object} node The node that called this method.
+ * @protected
+**/
+func (this *Tick) _haltNode(node *BaseNode) {
+	for _, l := range this._listeners {
+		l.HaltNode(this, node.outerNode())
+	}
+}
+
+/**
+ * 从后往前(最深的节点先)halt nodes[start:]中仍处于open状态的节点。
+ * nodes是一条从当前位置开始的open链，其中的子树节点之后的节点属于子树：
+ * halt这些节点时，tick的子树栈和黑板端口映射会恢复成执行时的样子。
+**/
+func (this *Tick) _haltNodes(nodes []IBaseNode, start int) {
+	blackboard := this.Blackboard
+	subtrees := this._openSubtreeNodes
+	defer func() {
+		this.Blackboard = blackboard
+		this._openSubtreeNodes = subtrees
+	}()
+
+	for i := len(nodes) - 1; i >= start; i-- {
+		node := nodes[i]
+		// 恢复node之前的子树调用栈
+		this.Blackboard = blackboard
+		this._openSubtreeNodes = append([]*SubTree(nil), subtrees...)
+		this._enterSubtrees(nodes[:i])
+		if !this.Blackboard.GetBool("isOpen", this.tree.id, node.GetID()) {
+			continue
+		}
+		node._halt(this)
+	}
+}
+
+// _enterSubtrees 依次进入nodes中的子树节点，恢复执行它们之后的节点时的子树栈和黑板视图
+func (this *Tick) _enterSubtrees(nodes []IBaseNode) {
+	for _, node := range nodes {
+		if st, ok := getBaseNode(node).GetBaseNodeWorker().(*SubTree); ok {
+			st._enter(this)
+		}
+	}
 }
 
 func (this *Tick) pushSubtreeNode(node *SubTree) {
@@ -153,6 +285,27 @@ func (this *Tick) GetLastSubTree() *SubTree {
 	return nil
 }
 
+/**
+ * NodeKey identifies a node being executed (or halted) in the tick: the IDs
+ * of the `tree` nodes calling the current subtree, then the node ID, joined
+ * by "/". "3/1" is the node 1 of the subtree called by the node 3 of the
+ * ticked tree: a subtree called from two places has different keys. The
+ * node memory of the blackboard, and the open state of the nodes, are kept
+ * by the same keys (see `Blackboard.Remap`).
+**/
+func (this *Tick) NodeKey(node IBaseNode) string {
+	if len(this._openSubtreeNodes) == 0 {
+		return node.GetID()
+	}
+	var key strings.Builder
+	for _, st := range this._openSubtreeNodes {
+		key.WriteString(st.GetID())
+		key.WriteByte('/')
+	}
+	key.WriteString(node.GetID())
+	return key.String()
+}
+
 /**
  * Callback when exiting a node (called by BaseNode).
  * @method _exitNode
@@ -160,7 +313,23 @@ func (this *Tick) GetLastSubTree() *SubTree {
  * @protected
 **/
 func (this *Tick) _exitNode(node *BaseNode) {
-	// TODO: call debug here
+	for _, l := range this._listeners {
+		l.ExitNode(this, node.outerNode())
+	}
+
+	ulen := len(this._nodeStack)
+	if ulen > 0 {
+		this._nodeStack = this._nodeStack[:ulen-1]
+	}
+}
+
+// currentNode 返回正在执行的节点
+func (this *Tick) currentNode() *BaseNode {
+	ulen := len(this._nodeStack)
+	if ulen > 0 {
+		return this._nodeStack[ulen-1]
+	}
+	return nil
 }
 
 func (this *Tick) GetTarget() interface{} {
@@ -0,0 +1,307 @@
+/*
+Package coverage reports which nodes of behavior trees were executed, and
+which statuses each node returned, like `go test -cover` for trees.
+
+	collector := coverage.New()
+	collector.Attach(tree)
+	... // tests or simulation
+	profile := collector.Profile()
+	profile.WriteText(os.Stdout)
+	profile.SaveFile("ai.b3cover")
+
+A node is covered when it was entered, and its statuses are covered when it
+returned both SUCCESS and FAILURE: a condition that never failed, or a
+Priority child never reached, show up in the report. RUNNING and ERROR are
+counted but not expected.
+
+Profiles of several runs are merged with `Merge`; the b3cover command
+merges profile files and writes the text or HTML report. Trees are
+identified by the ID of their config, or by title when they have none, and
+nodes by `core.Tick.NodeKey`: the nodes of a subtree are covered separately
+for each `tree` node calling it.
+*/
+package coverage
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// Expected are the statuses every node should return at least once.
+var Expected = []b3.Status{b3.SUCCESS, b3.FAILURE}
+
+// NodeCoverage is the coverage of one node.
+type NodeCoverage struct {
+	Key      string `json:"key"` // see core.Tick.NodeKey
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	// Depth in the tree, the nodes of a subtree are below their tree node.
+	Depth int `json:"depth"`
+
+	Entered uint64 `json:"entered"`
+	Success uint64 `json:"success"`
+	Failure uint64 `json:"failure"`
+	Running uint64 `json:"running"`
+	Error   uint64 `json:"error"`
+	Halted  uint64 `json:"halted"`
+}
+
+// Count returns the number of ticks of the node that returned status.
+func (this *NodeCoverage) Count(status b3.Status) uint64 {
+	switch status {
+	case b3.SUCCESS:
+		return this.Success
+	case b3.FAILURE:
+		return this.Failure
+	case b3.RUNNING:
+		return this.Running
+	case b3.ERROR:
+		return this.Error
+	}
+	return 0
+}
+
+// Missing returns the Expected statuses the node never returned.
+func (this *NodeCoverage) Missing() []b3.Status {
+	var missing []b3.Status
+	for _, status := range Expected {
+		if this.Count(status) == 0 {
+			missing = append(missing, status)
+		}
+	}
+	return missing
+}
+
+func (this *NodeCoverage) add(other *NodeCoverage) {
+	this.Entered += other.Entered
+	this.Success += other.Success
+	this.Failure += other.Failure
+	this.Running += other.Running
+	this.Error += other.Error
+	this.Halted += other.Halted
+}
+
+// TreeCoverage is the coverage of the nodes of one tree, in depth-first
+// order.
+type TreeCoverage struct {
+	Tree  string         `json:"tree"`         // title of the tree, or its ID
+	ID    string         `json:"id,omitempty"` // ID of the config of the tree
+	Nodes []NodeCoverage `json:"nodes"`
+}
+
+// sameTree 有配置ID时按ID匹配，都没有ID时才按标题匹配
+func (this *TreeCoverage) sameTree(other *TreeCoverage) bool {
+	if this.ID != "" || other.ID != "" {
+		return this.ID == other.ID
+	}
+	return this.Tree == other.Tree
+}
+
+// Entered returns the number of nodes entered, and the number of nodes.
+func (this *TreeCoverage) Entered() (covered, total int) {
+	for i := range this.Nodes {
+		if this.Nodes[i].Entered > 0 {
+			covered++
+		}
+	}
+	return covered, len(this.Nodes)
+}
+
+// Statuses returns the number of Expected statuses returned by the nodes,
+// and the number of Expected statuses of all the nodes.
+func (this *TreeCoverage) Statuses() (covered, total int) {
+	for i := range this.Nodes {
+		covered += len(Expected) - len(this.Nodes[i].Missing())
+	}
+	return covered, len(Expected) * len(this.Nodes)
+}
+
+// Profile is the coverage of a set of trees.
+type Profile struct {
+	Trees []TreeCoverage `json:"trees"`
+}
+
+/**
+ * Merge adds the counts of other to the profile. The trees are matched by
+ * config ID, by title for trees without ID, and the nodes by key; the trees
+ * and nodes missing from the profile are added.
+**/
+func (this *Profile) Merge(other *Profile) {
+	for i := range other.Trees {
+		src := &other.Trees[i]
+		var dst *TreeCoverage
+		for j := range this.Trees {
+			if this.Trees[j].sameTree(src) {
+				dst = &this.Trees[j]
+				break
+			}
+		}
+		if dst == nil {
+			this.Trees = append(this.Trees, TreeCoverage{Tree: src.Tree, ID: src.ID})
+			dst = &this.Trees[len(this.Trees)-1]
+		}
+		index := make(map[string]int, len(dst.Nodes))
+		for j := range dst.Nodes {
+			index[dst.Nodes[j].Key] = j
+		}
+		for j := range src.Nodes {
+			if k, ok := index[src.Nodes[j].Key]; ok {
+				dst.Nodes[k].add(&src.Nodes[j])
+			} else {
+				dst.Nodes = append(dst.Nodes, src.Nodes[j])
+			}
+		}
+	}
+}
+
+// Save writes the profile as JSON.
+func (this *Profile) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(this)
+}
+
+// SaveFile writes the profile to a file.
+func (this *Profile) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := this.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads a profile written by Save.
+func Load(r io.Reader) (*Profile, error) {
+	profile := &Profile{}
+	if err := json.NewDecoder(r).Decode(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// LoadFile reads a profile file.
+func LoadFile(path string) (*Profile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+//------------------------Collector-------------------------
+
+// Collector collects the coverage of the attached trees. It is safe for
+// concurrent use: the trees can be ticked on several goroutines, and
+// attached or detached while they tick.
+type Collector struct {
+	trees TreeListeners[*treeCoverage]
+}
+
+// treeCoverage 一棵树的listener
+type treeCoverage struct {
+	BaseTickListener
+	mu    sync.Mutex
+	nodes []*NodeCoverage
+	index map[string]*NodeCoverage
+}
+
+func New() *Collector {
+	return &Collector{}
+}
+
+// Attach starts collecting the coverage of tree. All the nodes of the tree
+// and of the subtrees that can be loaded are listed in the profile, even
+// if never executed.
+func (this *Collector) Attach(tree *BehaviorTree) {
+	this.trees.Attach(tree, func() *treeCoverage {
+		c := &treeCoverage{index: make(map[string]*NodeCoverage)}
+		tree.WalkKeys(func(node IBaseNode, key string, depth int) bool {
+			c._node(node, key, depth)
+			return true
+		})
+		return c
+	})
+}
+
+// Detach stops collecting the coverage of tree, and drops it.
+func (this *Collector) Detach(tree *BehaviorTree) {
+	this.trees.Detach(tree)
+}
+
+// Profile returns a copy of the coverage collected so far. Trees loaded
+// from the same config (or with the same title, without config ID) are
+// merged.
+func (this *Collector) Profile() *Profile {
+	profile := &Profile{}
+	// 按Attach的顺序输出
+	this.trees.Each(func(tree *BehaviorTree, c *treeCoverage) {
+		c.mu.Lock()
+		cov := TreeCoverage{Tree: tree.GetDisplayName(), ID: tree.GetCfgID(), Nodes: make([]NodeCoverage, len(c.nodes))}
+		for i, node := range c.nodes {
+			cov.Nodes[i] = *node
+		}
+		c.mu.Unlock()
+		profile.Merge(&Profile{Trees: []TreeCoverage{cov}})
+	})
+	return profile
+}
+
+// _node 返回节点的统计，调用时持有锁
+func (this *treeCoverage) _node(node IBaseNode, key string, depth int) *NodeCoverage {
+	n, ok := this.index[key]
+	if !ok {
+		n = &NodeCoverage{
+			Key:      key,
+			ID:       node.GetID(),
+			Name:     node.GetName(),
+			Title:    node.GetTitle(),
+			Category: node.GetCategory(),
+			Depth:    depth,
+		}
+		if _, isSubTree := node.(*SubTree); isSubTree {
+			n.Category = "tree"
+		}
+		this.index[key] = n
+		this.nodes = append(this.nodes, n)
+	}
+	return n
+}
+
+func (this *treeCoverage) EnterNode(tick *Tick, node IBaseNode) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	// 加载时不能展开的子树，节点在执行时加入，深度未知
+	this._node(node, tick.NodeKey(node), 0).Entered++
+}
+
+func (this *treeCoverage) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	n := this._node(node, tick.NodeKey(node), 0)
+	switch status {
+	case b3.SUCCESS:
+		n.Success++
+	case b3.FAILURE:
+		n.Failure++
+	case b3.RUNNING:
+		n.Running++
+	case b3.ERROR:
+		n.Error++
+	}
+}
+
+func (this *treeCoverage) HaltNode(tick *Tick, node IBaseNode) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this._node(node, tick.NodeKey(node), 0).Halted++
+}
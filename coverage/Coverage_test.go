@@ -0,0 +1,130 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	b3 "behavior3go"
+	"behavior3go/b3test"
+	. "behavior3go/config"
+	. "behavior3go/core"
+)
+
+func TestCoverage(t *testing.T) {
+	tree := b3test.Load(&BTTreeCfg{
+		Title: "guard",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "3", "4"}},
+			"2": {Id: "2", Name: "SeeEnemy", Category: "condition", Title: "see enemy"},
+			"3": {Id: "3", Name: "Patrol", Category: "action", Title: "patrol"},
+			"4": {Id: "4", Name: "Idle", Category: "action", Title: "idle"},
+		},
+	}, nil)
+	b3test.FindMock(tree, "see enemy").Script(b3.FAILURE)
+	b3test.FindMock(tree, "patrol").Script(b3.RUNNING, b3.SUCCESS)
+
+	collector := New()
+	collector.Attach(tree)
+	b3test.Run(tree, nil, NewBlackboard(), 2)
+	profile := collector.Profile()
+	if len(profile.Trees) != 1 || len(profile.Trees[0].Nodes) != 4 {
+		t.Fatalf("profile = %+v", profile)
+	}
+	cov := &profile.Trees[0]
+	if entered, total := cov.Entered(); entered != 3 || total != 4 {
+		t.Errorf("entered %d/%d, want 3/4", entered, total)
+	}
+	see := &cov.Nodes[1]
+	if see.Title != "see enemy" || see.Depth != 1 || see.Failure != 2 || len(see.Missing()) != 1 || see.Missing()[0] != b3.SUCCESS {
+		t.Errorf("see enemy = %+v", see)
+	}
+
+	// another run covers the missing statuses
+	b3test.FindMock(tree, "see enemy").Script(b3.SUCCESS)
+	b3test.Run(tree, nil, NewBlackboard(), 1)
+	var buf bytes.Buffer
+	profile.Save(&buf)
+	merged, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged.Merge(collector.Profile())
+	if see := &merged.Trees[0].Nodes[1]; see.Entered != 5 || see.Success != 1 {
+		t.Errorf("merged see enemy = %+v", see)
+	}
+
+	buf.Reset()
+	merged.WriteText(&buf)
+	text := buf.String()
+	for _, line := range []string{
+		"guard: nodes 3/4 (75.0%), statuses 4/8 (50.0%)",
+		"patrol(Patrol)",
+		"missing FAILURE",
+		"4 idle(Idle)",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("missing %q in\n%s", line, text)
+		}
+	}
+	if !strings.Contains(text[strings.Index(text, "4 idle"):], "NEVER ENTERED") {
+		t.Errorf("idle should never be entered:\n%s", text)
+	}
+
+	buf.Reset()
+	if err := merged.WriteHTML(&buf); err != nil || !strings.Contains(buf.String(), `<tr class="never">`) {
+		t.Errorf("html report: %v\n%s", err, buf.String())
+	}
+}
+
+func TestMergeByID(t *testing.T) {
+	load := func(id, title string) *BehaviorTree {
+		return b3test.Load(&BTTreeCfg{
+			ID:    id,
+			Title: title,
+			Root:  "1",
+			Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "idle"}},
+		}, nil)
+	}
+	collector := New()
+	// 同名的两棵树分开统计，改名的树和原来的树合并
+	trees := []*BehaviorTree{load("a", "guard"), load("b", "guard"), load("a", "renamed"), load("", "plain"), load("", "plain")}
+	for _, tree := range trees {
+		collector.Attach(tree)
+		b3test.Run(tree, nil, NewBlackboard(), 1)
+	}
+	profile := collector.Profile()
+	var got []string
+	for _, tree := range profile.Trees {
+		got = append(got, fmt.Sprintf("%s:%s:%d", tree.ID, tree.Tree, tree.Nodes[0].Entered))
+	}
+	want := "a:guard:2 b:guard:1 :plain:2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("trees = %v, want %s", got, want)
+	}
+}
+
+func TestCollectorConcurrent(t *testing.T) {
+	tree := b3test.Load(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "idle"}},
+	}, nil)
+	collector := New()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b3test.Run(tree, nil, NewBlackboard(), 500)
+	}()
+	// 其他goroutine tick时Attach和Detach
+	for i := 0; i < 100; i++ {
+		collector.Attach(tree)
+		collector.Profile()
+		collector.Detach(tree)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	b3 "behavior3go"
+)
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+func statusNames(statuses []b3.Status) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = status.String()
+	}
+	return strings.Join(names, " ")
+}
+
+func (this *NodeCoverage) label() string {
+	return fmt.Sprintf("%s %s(%s)", this.Key, this.Title, this.Name)
+}
+
+/**
+ * WriteText writes the report as text: for every tree the nodes entered
+ * and the statuses covered, then every node with its counts and what it
+ * misses:
+ *
+ *     guard: nodes 4/5 (80.0%), statuses 6/10 (60.0%)
+ *       1 root(Priority)              entered 3  S 1 F 0 R 2  missing FAILURE
+ *         5 patrol(Patrol)            NEVER ENTERED
+**/
+func (this *Profile) WriteText(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for i := range this.Trees {
+		tree := &this.Trees[i]
+		entered, total := tree.Entered()
+		covered, expected := tree.Statuses()
+		fmt.Fprintf(out, "%s: nodes %d/%d (%.1f%%), statuses %d/%d (%.1f%%)\n", tree.Tree,
+			entered, total, percent(entered, total), covered, expected, percent(covered, expected))
+		for j := range tree.Nodes {
+			node := &tree.Nodes[j]
+			label := strings.Repeat("  ", node.Depth+1) + node.label()
+			if node.Entered == 0 {
+				fmt.Fprintf(out, "%-40s NEVER ENTERED\n", label)
+				continue
+			}
+			fmt.Fprintf(out, "%-40s entered %d  S %d F %d R %d", label, node.Entered, node.Success, node.Failure, node.Running)
+			if node.Error > 0 {
+				fmt.Fprintf(out, " E %d", node.Error)
+			}
+			if node.Halted > 0 {
+				fmt.Fprintf(out, "  halted %d", node.Halted)
+			}
+			if missing := node.Missing(); len(missing) > 0 {
+				fmt.Fprintf(out, "  missing %s", statusNames(missing))
+			}
+			fmt.Fprintln(out)
+		}
+	}
+	return out.Flush()
+}
+
+var htmlReport = template.Must(template.New("coverage").Funcs(template.FuncMap{
+	"percent": percent,
+	"indent":  func(depth int) int { return depth * 18 },
+	"missing": func(node *NodeCoverage) string { return statusNames(node.Missing()) },
+	"class":   rowClass,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>behavior tree coverage</title>
+<style>
+body { font: 13px sans-serif; margin: 20px; }
+table { border-collapse: collapse; margin-bottom: 30px; }
+th, td { padding: 2px 8px; border-bottom: 1px solid #ddd; text-align: right; }
+td.node { text-align: left; }
+tr.never td { background: #f6c4c4; }
+tr.partial td { background: #fbeaa5; }
+tr.full td { background: #c8ecc8; }
+.name { color: #888; }
+</style>
+</head>
+<body>
+{{range .Trees}}
+<h3>{{.Tree}}</h3>
+<p>nodes {{.Entered}}/{{.Total}} ({{printf "%.1f" (percent .Entered .Total)}}%),
+statuses {{.Covered}}/{{.Expected}} ({{printf "%.1f" (percent .Covered .Expected)}}%)</p>
+<table>
+<tr><th>node</th><th>entered</th><th>SUCCESS</th><th>FAILURE</th><th>RUNNING</th><th>ERROR</th><th>halted</th><th>missing</th></tr>
+{{range .Nodes}}<tr class="{{class .}}">
+<td class="node" style="padding-left: {{indent .Depth}}px">{{.Key}} {{.Title}} <span class="name">{{.Name}}</span></td>
+<td>{{.Entered}}</td><td>{{.Success}}</td><td>{{.Failure}}</td><td>{{.Running}}</td><td>{{.Error}}</td><td>{{.Halted}}</td><td>{{missing .}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// rowClass 节点行的颜色
+func rowClass(node *NodeCoverage) string {
+	switch {
+	case node.Entered == 0:
+		return "never"
+	case len(node.Missing()) > 0:
+		return "partial"
+	}
+	return "full"
+}
+
+// htmlTree 模板使用的树
+type htmlTree struct {
+	Tree              string
+	Nodes             []*NodeCoverage
+	Entered, Total    int
+	Covered, Expected int
+}
+
+// WriteHTML writes the report as an HTML page: a table per tree, the nodes
+// never entered in red and the nodes missing statuses in yellow.
+func (this *Profile) WriteHTML(w io.Writer) error {
+	var trees []htmlTree
+	for i := range this.Trees {
+		tree := &this.Trees[i]
+		t := htmlTree{Tree: tree.Tree}
+		t.Entered, t.Total = tree.Entered()
+		t.Covered, t.Expected = tree.Statuses()
+		for j := range tree.Nodes {
+			t.Nodes = append(t.Nodes, &tree.Nodes[j])
+		}
+		trees = append(trees, t)
+	}
+	return htmlReport.Execute(w, struct{ Trees []htmlTree }{trees})
+}
@@ -0,0 +1,390 @@
+/*
+Package debugger pauses the ticks of a behavior tree on breakpoints and
+steps through the nodes one at a time.
+
+	dbg := debugger.New(tree)
+	dbg.SetBreakpoint(debugger.Breakpoint{Node: "3", After: true, Status: []b3.Status{b3.FAILURE}})
+
+A breakpoint is set on a node key (see `core.Tick.NodeKey`: the node ID, or
+"5/2" for the node 2 of the subtree called by the `tree` node 5), before the
+execution of the node or after it, when its status is known. It can be
+limited to some statuses, to one agent, and to a predicate on the tick
+(reading the blackboard for example).
+
+A tick reaching a breakpoint blocks until `Continue` or `Step` is called
+from another goroutine; `Step` pauses again at the next node event (the
+next node executed, or a node returning) of the same agent. `OnPause`
+callbacks are told about every pause, the debug server uses them. While
+paused, the tick and its blackboard can be inspected through `Pause.Tick`.
+
+In tests, `Tick` runs the tick on a new goroutine and gives back control
+at the first pause:
+
+	pause, _ := dbg.Tick(target, board)   // paused at a breakpoint
+	dbg.Step()
+	pause, _ = dbg.Wait()                 // paused at the next node event
+	dbg.Continue()
+	_, status := dbg.Wait()               // nil: the tick ended
+
+The debugger is an interceptor of the tree (see `core.Interceptor`): it can
+not be removed from the tree, `Close` disables it.
+*/
+package debugger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// Breakpoint pauses the tick on a node.
+type Breakpoint struct {
+	// ID is set by SetBreakpoint.
+	ID int
+	// Node is the key of the node, see core.Tick.NodeKey.
+	Node string
+	// After pauses when the node returns, instead of before its execution.
+	After bool
+	// Status limits a breakpoint After to these statuses, empty for all.
+	Status []b3.Status
+	// Agent limits the breakpoint to the agent owning this blackboard.
+	Agent *Blackboard
+	// Cond limits the breakpoint to the ticks where it returns true. It is
+	// called on the goroutine ticking the tree.
+	Cond func(tick *Tick) bool
+}
+
+func (this *Breakpoint) match(tick *Tick, key string, after bool, status b3.Status) bool {
+	if this.Node != key || this.After != after {
+		return false
+	}
+	if this.Agent != nil && tick.Blackboard.Base() != this.Agent {
+		return false
+	}
+	if after && len(this.Status) > 0 {
+		found := false
+		for _, s := range this.Status {
+			found = found || s == status
+		}
+		if !found {
+			return false
+		}
+	}
+	return this.Cond == nil || this.Cond(tick)
+}
+
+// Pause is a tick paused on a node.
+type Pause struct {
+	Tick *Tick
+	Node IBaseNode
+	// Key of the node, see core.Tick.NodeKey.
+	Key string
+	// After is true when the node returned Status.
+	After  bool
+	Status b3.Status
+	// Breakpoint is the ID of the breakpoint, 0 after a Step.
+	Breakpoint int
+	// Stack are the nodes being executed, from the root to Node.
+	Stack []IBaseNode
+}
+
+func (this *Pause) String() string {
+	where := "before"
+	if this.After {
+		where = "after"
+	}
+	text := fmt.Sprintf("%s %s %s(%s)", where, this.Key, this.Node.GetTitle(), this.Node.GetName())
+	if this.After {
+		text += " -> " + this.Status.String()
+	}
+	return text
+}
+
+// run 由Debugger.Tick启动的tick
+type run struct {
+	agent  *Blackboard
+	pauses chan *Pause
+	done   chan b3.Status
+	panic  interface{}
+}
+
+// Debugger pauses the ticks of one tree. It is safe for concurrent use.
+type Debugger struct {
+	tree *BehaviorTree
+
+	mu          sync.Mutex
+	closed      bool
+	breakpoints []*Breakpoint
+	nextID      int
+	// Step之后在这个对象的下一个节点事件暂停
+	stepping  bool
+	stepAgent *Blackboard
+	current   *Pause
+	resume    chan struct{}
+	onPause   []func(*Pause)
+	stacks    map[*Tick][]IBaseNode
+	run       *run
+
+	// 同一时间只有一个tick暂停
+	pauseMu sync.Mutex
+}
+
+// New creates a debugger for tree, without breakpoints.
+func New(tree *BehaviorTree) *Debugger {
+	d := &Debugger{tree: tree, stacks: make(map[*Tick][]IBaseNode)}
+	tree.Use(d.intercept)
+	return d
+}
+
+func (this *Debugger) GetTree() *BehaviorTree {
+	return this.tree
+}
+
+// SetBreakpoint adds a breakpoint and returns its ID.
+func (this *Debugger) SetBreakpoint(bp Breakpoint) int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.nextID++
+	bp.ID = this.nextID
+	bp.Status = append([]b3.Status(nil), bp.Status...)
+	this.breakpoints = append(this.breakpoints[:len(this.breakpoints):len(this.breakpoints)], &bp)
+	return bp.ID
+}
+
+// ClearBreakpoint removes a breakpoint, it returns false if there is no
+// breakpoint with this ID.
+func (this *Debugger) ClearBreakpoint(id int) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for i, bp := range this.breakpoints {
+		if bp.ID == id {
+			breakpoints := make([]*Breakpoint, 0, len(this.breakpoints)-1)
+			breakpoints = append(breakpoints, this.breakpoints[:i]...)
+			this.breakpoints = append(breakpoints, this.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearBreakpoints removes all the breakpoints.
+func (this *Debugger) ClearBreakpoints() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.breakpoints = nil
+}
+
+// Breakpoints returns a copy of the breakpoints, sorted by ID.
+func (this *Debugger) Breakpoints() []Breakpoint {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	list := make([]Breakpoint, len(this.breakpoints))
+	for i, bp := range this.breakpoints {
+		list[i] = *bp
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// OnPause registers a callback called on every pause, on the goroutine
+// ticking the tree, before it blocks. It must not block.
+func (this *Debugger) OnPause(fn func(*Pause)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.onPause = append(this.onPause, fn)
+}
+
+// Current returns the paused tick, or nil.
+func (this *Debugger) Current() *Pause {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.current
+}
+
+// Continue resumes the paused tick. It returns false if no tick is paused.
+func (this *Debugger) Continue() bool {
+	return this._resume(false)
+}
+
+// Step resumes the paused tick until the next node event of the same agent.
+// It returns false if no tick is paused.
+func (this *Debugger) Step() bool {
+	return this._resume(true)
+}
+
+func (this *Debugger) _resume(step bool) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.current == nil {
+		return false
+	}
+	this.stepping = step
+	this.stepAgent = this.current.Tick.Blackboard.Base()
+	close(this.resume)
+	this.current = nil
+	this.resume = nil
+	return true
+}
+
+// Close removes the breakpoints, stops stepping and resumes the paused
+// tick. The debugger does nothing afterwards.
+func (this *Debugger) Close() {
+	this.mu.Lock()
+	this.closed = true
+	this.breakpoints = nil
+	this.stepping = false
+	this.mu.Unlock()
+	this._resume(false)
+}
+
+/**
+ * Tick ticks the tree on a new goroutine, and returns at the first pause of
+ * this agent, or at the end of the tick with its status (the pause is nil).
+ * After a pause, call Continue or Step, then Wait. Only one tick can be
+ * run at a time; a panic of the tick is raised again by Wait.
+**/
+func (this *Debugger) Tick(target interface{}, blackboard *Blackboard) (*Pause, b3.Status) {
+	r := &run{agent: blackboard, pauses: make(chan *Pause, 1), done: make(chan b3.Status, 1)}
+	this.mu.Lock()
+	if this.run != nil {
+		this.mu.Unlock()
+		panic("debugger: a tick is already running")
+	}
+	this.run = r
+	this.mu.Unlock()
+
+	go func() {
+		status := b3.Status(0)
+		defer func() {
+			r.panic = recover()
+			r.done <- status
+		}()
+		status = this.tree.Tick(target, blackboard)
+	}()
+	return this.Wait()
+}
+
+// Wait waits for the next pause, or the end, of the tick started by Tick.
+// It returns nil and 0 if there is no such tick.
+func (this *Debugger) Wait() (*Pause, b3.Status) {
+	this.mu.Lock()
+	r := this.run
+	this.mu.Unlock()
+	if r == nil {
+		return nil, 0
+	}
+	select {
+	case pause := <-r.pauses:
+		return pause, 0
+	case status := <-r.done:
+		this.mu.Lock()
+		this.run = nil
+		this.mu.Unlock()
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		return nil, status
+	}
+}
+
+//------------------------interceptor-------------------------
+
+func (this *Debugger) intercept(next ExecFunc) ExecFunc {
+	return func(tick *Tick, node IBaseNode) b3.Status {
+		key := tick.NodeKey(node)
+		this._push(tick, node)
+		defer this._pop(tick)
+
+		this._check(tick, node, key, false, 0)
+		status := next(tick, node)
+		this._check(tick, node, key, true, status)
+		return status
+	}
+}
+
+func (this *Debugger) _push(tick *Tick, node IBaseNode) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.stacks[tick] = append(this.stacks[tick], node)
+}
+
+func (this *Debugger) _pop(tick *Tick) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	stack := this.stacks[tick]
+	if len(stack) <= 1 {
+		delete(this.stacks, tick)
+		return
+	}
+	this.stacks[tick] = stack[:len(stack)-1]
+}
+
+// _check 在节点执行前后检查是否暂停
+func (this *Debugger) _check(tick *Tick, node IBaseNode, key string, after bool, status b3.Status) {
+	this.mu.Lock()
+	if this.closed {
+		this.mu.Unlock()
+		return
+	}
+	stepping := this.stepping && this.stepAgent == tick.Blackboard.Base()
+	breakpoints := this.breakpoints
+	this.mu.Unlock()
+
+	pause := &Pause{Tick: tick, Node: node, Key: key, After: after, Status: status}
+	if !stepping {
+		// 条件在锁外调用
+		for _, bp := range breakpoints {
+			if bp.match(tick, key, after, status) {
+				pause.Breakpoint = bp.ID
+				break
+			}
+		}
+		if pause.Breakpoint == 0 {
+			return
+		}
+	}
+	this._pause(pause)
+}
+
+// _pause 阻塞直到Continue或Step
+func (this *Debugger) _pause(pause *Pause) {
+	this.pauseMu.Lock()
+	defer this.pauseMu.Unlock()
+
+	this.mu.Lock()
+	if this.closed {
+		this.mu.Unlock()
+		return
+	}
+	if pause.Breakpoint == 0 {
+		// 等待其他暂停时可能已经不再单步
+		if !this.stepping || this.stepAgent != pause.Tick.Blackboard.Base() {
+			this.mu.Unlock()
+			return
+		}
+	}
+	this.stepping = false
+	pause.Stack = append([]IBaseNode(nil), this.stacks[pause.Tick]...)
+	resume := make(chan struct{})
+	this.current = pause
+	this.resume = resume
+	callbacks := this.onPause
+	r := this.run
+	this.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(pause)
+	}
+	if r != nil && r.agent == pause.Tick.Blackboard.Base() {
+		// 只保留最新的暂停
+		select {
+		case <-r.pauses:
+		default:
+		}
+		r.pauses <- pause
+	}
+	<-resume
+}
@@ -0,0 +1,89 @@
+package debugger
+
+import (
+	"testing"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+)
+
+func TestBreakpoints(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "ok"},
+			"3": {Id: "3", Name: "Runner", Title: "run"},
+		},
+	}, nil)
+	dbg := New(tree)
+	board := NewBlackboard()
+
+	expect := func(pause *Pause, want string) {
+		t.Helper()
+		if pause == nil {
+			t.Fatalf("not paused, want %s", want)
+		}
+		if pause.String() != want {
+			t.Fatalf("paused %s, want %s", pause, want)
+		}
+	}
+
+	id := dbg.SetBreakpoint(Breakpoint{Node: "2"})
+	pause, _ := dbg.Tick(nil, board)
+	expect(pause, "before 2 ok(Succeeder)")
+	if pause.Breakpoint != id || len(pause.Stack) != 2 || dbg.Current() != pause {
+		t.Fatalf("pause = %+v", pause)
+	}
+	dbg.Step()
+	pause, _ = dbg.Wait()
+	expect(pause, "after 2 ok(Succeeder) -> SUCCESS")
+	dbg.Step()
+	pause, _ = dbg.Wait()
+	expect(pause, "before 3 run(Runner)")
+	dbg.Continue()
+	if pause, status := dbg.Wait(); pause != nil || status != b3.RUNNING {
+		t.Fatalf("end of tick = %v %v, want RUNNING", pause, status)
+	}
+	if dbg.Current() != nil || dbg.Continue() {
+		t.Fatal("still paused after the tick")
+	}
+
+	// breakpoints on the status and on the blackboard
+	dbg.ClearBreakpoint(id)
+	dbg.SetBreakpoint(Breakpoint{Node: "3", After: true, Status: []b3.Status{b3.FAILURE}})
+	alert := dbg.SetBreakpoint(Breakpoint{Node: "1", After: true, Cond: func(tick *Tick) bool {
+		return tick.Blackboard.GetBool("alert", "", "")
+	}})
+	if pause, status := dbg.Tick(nil, board); pause != nil || status != b3.RUNNING {
+		t.Fatalf("tick = %v %v, want no pause", pause, status)
+	}
+	board.SetMem("alert", true)
+	pause, _ = dbg.Tick(nil, board)
+	expect(pause, "after 1 root(Sequence) -> RUNNING")
+	if pause.Breakpoint != alert || !pause.Tick.Blackboard.GetBool("alert", "", "") {
+		t.Fatalf("pause = %+v", pause)
+	}
+
+	dbg.Continue()
+	dbg.Wait()
+
+	// a breakpoint of an agent does not pause the others
+	dbg.ClearBreakpoints()
+	dbg.SetBreakpoint(Breakpoint{Node: "1", Agent: board})
+	if pause, _ := dbg.Tick(nil, NewBlackboard()); pause != nil {
+		t.Fatalf("other agent paused %s", pause)
+	}
+	pause, _ = dbg.Tick(nil, board)
+	expect(pause, "before 1 root(Sequence)")
+
+	dbg.Close()
+	if _, status := dbg.Wait(); status != b3.RUNNING {
+		t.Fatalf("status after Close = %v", status)
+	}
+	if len(dbg.Breakpoints()) != 0 {
+		t.Error("Close kept the breakpoints")
+	}
+}
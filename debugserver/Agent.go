@@ -0,0 +1,335 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// 页面中节点的key与Tick.NodeKey相同，例如 "3/1" 是ID为3的tree节点调用的子树中ID为1的节点
+
+// nodeDesc 页面显示的树结构
+type nodeDesc struct {
+	Key      string      `json:"key"`
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	Title    string      `json:"title"`
+	Category string      `json:"category"`
+	SubTree  bool        `json:"subtree,omitempty"`
+	Children []*nodeDesc `json:"children,omitempty"`
+}
+
+// describeTree 生成树结构，展开可以加载到的子树
+func describeTree(tree *BehaviorTree) *nodeDesc {
+	// stack[depth] 是当前路径上深度为depth的节点
+	var stack []*nodeDesc
+	tree.WalkKeys(func(node IBaseNode, key string, depth int) bool {
+		desc := &nodeDesc{
+			Key:      key,
+			ID:       node.GetID(),
+			Name:     node.GetName(),
+			Title:    node.GetTitle(),
+			Category: node.GetCategory(),
+		}
+		if _, ok := node.(*SubTree); ok {
+			desc.SubTree = true
+			desc.Category = "tree"
+		}
+		stack = append(stack[:depth], desc)
+		if depth > 0 {
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, desc)
+		}
+		return true
+	})
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[0]
+}
+
+// bbEntry 黑板中的一个值，或者一次修改
+type bbEntry struct {
+	Key     string          `json:"key"`
+	Tree    string          `json:"tree,omitempty"`
+	Node    string          `json:"node,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Removed bool            `json:"removed,omitempty"`
+}
+
+func (this bbEntry) id() string {
+	return this.Tree + "\x00" + this.Node + "\x00" + this.Key
+}
+
+// frame 发送给页面的消息
+type frame struct {
+	Type   string      `json:"type"` // init, tick, halt, pause
+	Agent  string      `json:"agent"`
+	Tree   *nodeDesc   `json:"tree,omitempty"`
+	Tick   int         `json:"tick"`
+	Status string      `json:"status,omitempty"`
+	Nodes  [][2]string `json:"nodes,omitempty"` // 按完成顺序的[key, status]
+	Open   []string    `json:"open"`
+	Halted []string    `json:"halted,omitempty"`
+	Errors []string    `json:"errors,omitempty"`
+	// tick帧中是本次的修改，init帧中是全部的值
+	Blackboard []bbEntry `json:"blackboard,omitempty"`
+	// pause帧中暂停的位置
+	Pause *pauseInfo `json:"pause,omitempty"`
+}
+
+// agent 一个被观察的黑板，作为树的listener收集每次tick的状态
+type agent struct {
+	BaseTickListener
+	name string
+	tree *BehaviorTree
+	bb   *Blackboard
+	desc *nodeDesc
+	// 黑板只在tick的goroutine中读取和观察，见sync
+	watch WatchID
+
+	mu sync.Mutex
+	// 已读取黑板并开始观察；Detach之后观察者在下一次修改时移除
+	synced   bool
+	detached bool
+	// tick中的状态
+	inTick  bool
+	current frame
+	pending []bbEntry
+	// 最近的状态，发给新连接的页面
+	ticks      int
+	lastStatus string
+	lastNodes  [][2]string
+	lastOpen   []string
+	values     map[string]bbEntry
+	clients    map[*client]bool
+}
+
+func newAgent(name string, tree *BehaviorTree, bb *Blackboard) *agent {
+	a := &agent{
+		name:    name,
+		tree:    tree,
+		bb:      bb,
+		desc:    describeTree(tree),
+		values:  make(map[string]bbEntry),
+		clients: make(map[*client]bool),
+	}
+	a.lastOpen = []string{}
+	return a
+}
+
+// sync 在tick的goroutine中第一次调用时读取黑板并开始观察修改，已连接的页面重新收到init帧
+func (this *agent) sync() {
+	this.mu.Lock()
+	synced := this.synced || this.detached
+	this.mu.Unlock()
+	if synced {
+		return
+	}
+	entries := this.readBlackboard()
+	open := this.openKeys()
+	this.watch = this.bb.WatchAll(this.onChange)
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.synced = true
+	for _, entry := range entries {
+		this.values[entry.id()] = entry
+	}
+	this.lastOpen = open
+	this._broadcast(this._initFrame())
+}
+
+// readBlackboard 读取黑板的全局、树和节点上下文
+func (this *agent) readBlackboard() []bbEntry {
+	var entries []bbEntry
+	add := func(tree, node string) func(string, interface{}) bool {
+		return func(key string, value interface{}) bool {
+			entries = append(entries, bbEntry{Key: key, Tree: tree, Node: node, Value: EncodeValue(value)})
+			return true
+		}
+	}
+	this.bb.Range(add("", ""))
+	this.bb.Range(add(this.tree.GetID(), ""), this.tree.GetID())
+	// 子树节点的内存按节点key保存(见Tick.NodeKey)
+	this.tree.WalkKeys(func(node IBaseNode, key string, depth int) bool {
+		this.bb.Range(add(this.tree.GetID(), key), this.tree.GetID(), key)
+		return true
+	})
+	return entries
+}
+
+// openKeys 根据黑板中的open节点计算它们的key
+func (this *agent) openKeys() []string {
+	keys := []string{}
+	prefix := ""
+	for _, info := range this.tree.RunningPath(this.bb) {
+		key := prefix + info.ID
+		keys = append(keys, key)
+		if info.IsSubTree {
+			prefix = key + "/"
+		}
+	}
+	return keys
+}
+
+// onChange 黑板的修改，在下一帧发送
+func (this *agent) onChange(change BlackboardChange) {
+	entry := bbEntry{Key: change.Key, Tree: change.TreeScope, Node: change.NodeScope, Removed: change.Removed}
+	if !change.Removed {
+		entry.Value = EncodeValue(change.New)
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.detached {
+		// Detach可能在其他goroutine中调用，在修改黑板的goroutine中移除观察者
+		this.bb.Unwatch(this.watch)
+		return
+	}
+	this.pending = append(this.pending, entry)
+}
+
+func (this *agent) mine(tick *Tick) bool {
+	return tick.Blackboard.Base() == this.bb
+}
+
+func (this *agent) TickStart(tick *Tick) {
+	if !this.mine(tick) {
+		return
+	}
+	this.sync()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.inTick = true
+	this.current = frame{Type: "tick", Agent: this.name}
+}
+
+func (this *agent) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	if !this.mine(tick) {
+		return
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.current.Nodes = append(this.current.Nodes, [2]string{tick.NodeKey(node), status.String()})
+}
+
+func (this *agent) HaltNode(tick *Tick, node IBaseNode) {
+	if !this.mine(tick) {
+		return
+	}
+	this.sync()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	key := tick.NodeKey(node)
+	if this.inTick {
+		this.current.Halted = append(this.current.Halted, key)
+		return
+	}
+	// BehaviorTree.Halt：立即发送
+	this.lastOpen = []string{}
+	this._broadcast(&frame{Type: "halt", Agent: this.name, Tick: this.ticks, Open: this.lastOpen, Halted: []string{key}})
+}
+
+func (this *agent) TickEnd(tick *Tick, status b3.Status) {
+	if !this.mine(tick) {
+		return
+	}
+	open := this.openKeys()
+	var errs []string
+	for _, err := range tick.Errors() {
+		errs = append(errs, err.Error())
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.inTick = false
+	this.ticks++
+	f := this.current
+	this.current = frame{}
+	f.Tick = this.ticks
+	f.Status = status.String()
+	f.Open = open
+	f.Errors = errs
+	f.Blackboard = this.pending
+	this.pending = nil
+	for _, entry := range f.Blackboard {
+		if entry.Removed {
+			delete(this.values, entry.id())
+		} else {
+			this.values[entry.id()] = entry
+		}
+	}
+	this.lastStatus = f.Status
+	this.lastNodes = f.Nodes
+	this.lastOpen = open
+	this._broadcast(&f)
+}
+
+// _initFrame 新连接收到的第一帧：树结构、最近一次tick的状态和黑板的全部值
+func (this *agent) _initFrame() *frame {
+	f := &frame{
+		Type:   "init",
+		Agent:  this.name,
+		Tree:   this.desc,
+		Tick:   this.ticks,
+		Status: this.lastStatus,
+		Nodes:  this.lastNodes,
+		Open:   this.lastOpen,
+	}
+	ids := make([]string, 0, len(this.values))
+	for id := range this.values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		f.Blackboard = append(f.Blackboard, this.values[id])
+	}
+	return f
+}
+
+// _broadcast 发送给所有页面，调用时持有锁
+func (this *agent) _broadcast(f *frame) {
+	if len(this.clients) == 0 {
+		return
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	for c := range this.clients {
+		if !c.send(data) {
+			// 跟不上的页面断开
+			delete(this.clients, c)
+			c.close()
+		}
+	}
+}
+
+func (this *agent) addClient(c *client) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if data, err := json.Marshal(this._initFrame()); err == nil {
+		c.send(data)
+	}
+	this.clients[c] = true
+}
+
+func (this *agent) removeClient(c *client) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.clients, c)
+}
+
+// detach 断开所有页面，之后不再观察黑板
+func (this *agent) detach() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.detached = true
+	for c := range this.clients {
+		c.close()
+	}
+	this.clients = make(map[*client]bool)
+}
@@ -0,0 +1,226 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	b3 "behavior3go"
+	"behavior3go/debugger"
+)
+
+// 断点和单步，见AddDebugger
+
+// pauseInfo 暂停的位置
+type pauseInfo struct {
+	Key        string `json:"key"`
+	After      bool   `json:"after,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Breakpoint int    `json:"breakpoint,omitempty"`
+}
+
+func newPauseInfo(p *debugger.Pause) *pauseInfo {
+	info := &pauseInfo{Key: p.Key, After: p.After, Breakpoint: p.Breakpoint}
+	if p.After {
+		info.Status = p.Status.String()
+	}
+	return info
+}
+
+type breakpointInfo struct {
+	ID     int      `json:"id"`
+	Node   string   `json:"node"`
+	After  bool     `json:"after,omitempty"`
+	Status []string `json:"status,omitempty"`
+}
+
+// debugState GET /debug 的结果
+type debugState struct {
+	Breakpoints []breakpointInfo `json:"breakpoints"`
+	Pause       *pauseInfo       `json:"pause"`
+	// 暂停时黑板的全部值
+	Blackboard []bbEntry `json:"blackboard,omitempty"`
+}
+
+/**
+ * AddDebugger lets the pages set breakpoints on the agents of the tree of
+ * dbg, and step through their paused ticks. The pages are sent a `pause`
+ * frame when an attached agent pauses.
+ *
+ * Endpoints, the agent is given with `?agent=`:
+ *
+ *     /debug           JSON state: breakpoints of the agent, pause, blackboard
+ *     /debug/break     POST, node=<key>[&after=1][&status=FAILURE,...]
+ *     /debug/clear     POST, id=<breakpoint id>
+ *     /debug/continue  POST, resume the paused tick
+ *     /debug/step      POST, pause at the next node event
+ *
+ * The breakpoints set from the pages only pause their agent.
+**/
+func (this *Server) AddDebugger(dbg *debugger.Debugger) {
+	this.mu.Lock()
+	this.debuggers[dbg.GetTree()] = dbg
+	this.mu.Unlock()
+	dbg.OnPause(this.onPause)
+}
+
+func (this *Server) onPause(p *debugger.Pause) {
+	this.mu.Lock()
+	var agents []*agent
+	for _, a := range this.agents {
+		if a.tree == p.Tick.GetTree() && a.mine(p.Tick) {
+			agents = append(agents, a)
+		}
+	}
+	this.mu.Unlock()
+	for _, a := range agents {
+		a.paused(newPauseInfo(p))
+	}
+}
+
+// debugAgent 返回请求的agent和它的debugger，失败时写入错误
+func (this *Server) debugAgent(w http.ResponseWriter, r *http.Request) (*agent, *debugger.Debugger) {
+	name := r.URL.Query().Get("agent")
+	this.mu.Lock()
+	a, ok := this.agents[name]
+	var dbg *debugger.Debugger
+	if ok {
+		dbg = this.debuggers[a.tree]
+	}
+	this.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown agent "+name, http.StatusNotFound)
+		return nil, nil
+	}
+	if dbg == nil {
+		http.Error(w, "no debugger for agent "+name, http.StatusNotFound)
+		return nil, nil
+	}
+	if r.URL.Path != "/debug" && r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return nil, nil
+	}
+	if r.Method == http.MethodPost && !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return nil, nil
+	}
+	return a, dbg
+}
+
+// sameOrigin 请求来自本服务的页面，或者不是浏览器发送的(没有Origin)。
+// 否则任何网页都可以设置断点让tick暂停
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (this *Server) serveDebug(w http.ResponseWriter, r *http.Request) {
+	a, dbg := this.debugAgent(w, r)
+	if a == nil {
+		return
+	}
+	state := debugState{Breakpoints: []breakpointInfo{}}
+	for _, bp := range dbg.Breakpoints() {
+		if bp.Agent != nil && bp.Agent != a.bb {
+			continue
+		}
+		info := breakpointInfo{ID: bp.ID, Node: bp.Node, After: bp.After}
+		for _, status := range bp.Status {
+			info.Status = append(info.Status, status.String())
+		}
+		state.Breakpoints = append(state.Breakpoints, info)
+	}
+	// tick暂停时黑板不会被修改，可以读取
+	if p := dbg.Current(); p != nil && a.mine(p.Tick) {
+		state.Pause = newPauseInfo(p)
+		state.Blackboard = a.readBlackboard()
+	}
+	writeJSON(w, state)
+}
+
+var statusNames = map[string]b3.Status{
+	"SUCCESS": b3.SUCCESS,
+	"FAILURE": b3.FAILURE,
+	"RUNNING": b3.RUNNING,
+	"ERROR":   b3.ERROR,
+}
+
+func (this *Server) serveBreak(w http.ResponseWriter, r *http.Request) {
+	a, dbg := this.debugAgent(w, r)
+	if a == nil {
+		return
+	}
+	bp := debugger.Breakpoint{Node: r.FormValue("node"), After: r.FormValue("after") == "1", Agent: a.bb}
+	if bp.Node == "" {
+		http.Error(w, "node required", http.StatusBadRequest)
+		return
+	}
+	if list := r.FormValue("status"); list != "" {
+		for _, name := range strings.Split(list, ",") {
+			status, ok := statusNames[strings.ToUpper(name)]
+			if !ok {
+				http.Error(w, "unknown status "+name, http.StatusBadRequest)
+				return
+			}
+			bp.Status = append(bp.Status, status)
+		}
+	}
+	writeJSON(w, map[string]int{"id": dbg.SetBreakpoint(bp)})
+}
+
+func (this *Server) serveClear(w http.ResponseWriter, r *http.Request) {
+	a, dbg := this.debugAgent(w, r)
+	if a == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	for _, bp := range dbg.Breakpoints() {
+		if bp.ID == id && bp.Agent == a.bb {
+			dbg.ClearBreakpoint(id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(w, "unknown breakpoint", http.StatusNotFound)
+}
+
+func (this *Server) serveResume(w http.ResponseWriter, r *http.Request) {
+	a, dbg := this.debugAgent(w, r)
+	if a == nil {
+		return
+	}
+	if p := dbg.Current(); p == nil || !a.mine(p.Tick) {
+		http.Error(w, "agent not paused", http.StatusConflict)
+		return
+	}
+	if r.URL.Path == "/debug/step" {
+		dbg.Step()
+	} else {
+		dbg.Continue()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// paused 发送暂停帧：到目前为止的节点状态和黑板修改
+func (this *agent) paused(p *pauseInfo) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	f := this.current
+	f.Type = "pause"
+	f.Tick = this.ticks + 1
+	f.Open = this.lastOpen
+	f.Pause = p
+	f.Blackboard = append([]bbEntry(nil), this.pending...)
+	this._broadcast(&f)
+}
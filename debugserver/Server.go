@@ -0,0 +1,215 @@
+/*
+Package debugserver streams the execution of behavior trees to a browser.
+
+A Server watches attached agents (a tree and the blackboard of one agent)
+and serves a page rendering the tree with the status of every node, live,
+the way the behavior3 editor shows it statically:
+
+	server := debugserver.New()
+	server.Attach("npc1", tree, npc1.board)
+	go server.ListenAndServe("127.0.0.1:8765")
+
+	// game loop
+	tree.Tick(npc1, npc1.board)
+
+Every tick of an attached agent sends to the connected pages the status of
+the executed nodes, the nodes left open (RUNNING), the halted nodes, the
+errors and the blackboard changes. With a debugger of the debugger package
+added, the page sets breakpoints on the nodes, and continues or steps the
+paused ticks while showing the blackboard. The server only uses the standard
+library: the page is embedded and the WebSocket endpoint is built in.
+
+Endpoints:
+
+	/            the page
+	/agents      JSON list of the attached agents
+	/ws?agent=   WebSocket stream of one agent, JSON frames
+	/debug/...   breakpoints, with a debugger (see AddDebugger)
+
+Attach and Detach can be called from any goroutine, while the trees tick.
+The blackboard of an agent is only read on the goroutine ticking it: its
+values are sent to the pages from the first tick (or Halt) after Attach.
+The server is meant for development: it has no authentication, listen on a
+local address. The POST requests of the debugger are refused when sent by
+a page of another origin.
+*/
+package debugserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	. "behavior3go/core"
+	"behavior3go/debugger"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// 每个页面缓存的帧数，页面跟不上时断开
+const clientBuffer = 256
+
+type Server struct {
+	mu        sync.Mutex
+	agents    map[string]*agent
+	debuggers map[*BehaviorTree]*debugger.Debugger
+	mux       *http.ServeMux
+}
+
+func New() *Server {
+	s := &Server{agents: make(map[string]*agent), debuggers: make(map[*BehaviorTree]*debugger.Debugger)}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/", s.serveIndex)
+	s.mux.HandleFunc("/agents", s.serveAgents)
+	s.mux.HandleFunc("/ws", s.serveWS)
+	s.mux.HandleFunc("/debug", s.serveDebug)
+	s.mux.HandleFunc("/debug/break", s.serveBreak)
+	s.mux.HandleFunc("/debug/clear", s.serveClear)
+	s.mux.HandleFunc("/debug/continue", s.serveResume)
+	s.mux.HandleFunc("/debug/step", s.serveResume)
+	return s
+}
+
+// Attach starts streaming the ticks of tree for the agent owning blackboard.
+// Attaching a name again replaces the previous agent.
+func (this *Server) Attach(name string, tree *BehaviorTree, blackboard *Blackboard) {
+	this.Detach(name)
+	a := newAgent(name, tree, blackboard)
+	tree.AddListener(a)
+
+	this.mu.Lock()
+	this.agents[name] = a
+	this.mu.Unlock()
+}
+
+// Detach stops streaming an agent and disconnects its pages.
+func (this *Server) Detach(name string) {
+	this.mu.Lock()
+	a, ok := this.agents[name]
+	delete(this.agents, name)
+	this.mu.Unlock()
+	if !ok {
+		return
+	}
+	a.tree.RemoveListener(a)
+	a.detach()
+}
+
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	this.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the debug page on addr.
+func (this *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, this)
+}
+
+func (this *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+type agentInfo struct {
+	Name  string `json:"name"`
+	Tree  string `json:"tree"`
+	Title string `json:"title"`
+}
+
+func (this *Server) serveAgents(w http.ResponseWriter, r *http.Request) {
+	this.mu.Lock()
+	list := make([]agentInfo, 0, len(this.agents))
+	for name, a := range this.agents {
+		list = append(list, agentInfo{name, a.tree.GetID(), a.tree.GetTitile()})
+	}
+	this.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (this *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("agent")
+	this.mu.Lock()
+	a, ok := this.agents[name]
+	this.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown agent "+name, http.StatusNotFound)
+		return
+	}
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	c := newClient(ws)
+	a.addClient(c)
+	go c.writeLoop()
+	ws.readLoop()
+	a.removeClient(c)
+	c.close()
+}
+
+//------------------------client-------------------------
+
+// client 一个连接的页面，帧通过send缓冲后由writeLoop发送
+type client struct {
+	ws     *wsConn
+	frames chan []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newClient(ws *wsConn) *client {
+	return &client{ws: ws, frames: make(chan []byte, clientBuffer), done: make(chan struct{})}
+}
+
+// send 缓冲一帧，缓冲满时返回false
+func (this *client) send(data []byte) bool {
+	select {
+	case this.frames <- data:
+		return true
+	case <-this.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeLoop 发送缓冲的帧，close后发送关闭帧并关闭连接
+func (this *client) writeLoop() {
+	defer this.ws.Close()
+	for {
+		// 关闭后不再发送缓冲的帧
+		select {
+		case <-this.done:
+			this.ws.writeFrame(opClose, nil)
+			return
+		default:
+		}
+		select {
+		case data := <-this.frames:
+			if this.ws.writeFrame(opText, data) != nil {
+				this.close()
+				return
+			}
+		case <-this.done:
+			this.ws.writeFrame(opClose, nil)
+			return
+		}
+	}
+}
+
+// close 不阻塞：在tick的goroutine中持有agent的锁时调用，写由writeLoop完成
+func (this *client) close() {
+	this.once.Do(func() {
+		close(this.done)
+	})
+}
@@ -0,0 +1,358 @@
+package debugserver
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	"behavior3go/debugger"
+	. "behavior3go/loader"
+)
+
+// dialWS 连接WebSocket并完成握手
+func dialWS(t *testing.T, addr, path string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: "+addr+"\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", accept)
+	}
+	return conn, reader
+}
+
+func readFrame(t *testing.T, reader *bufio.Reader) frame {
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x80|opText {
+		t.Fatalf("frame header = %x", header[0])
+	}
+	length := uint64(header[1])
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(reader, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(reader, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	var f frame
+	if err := json.Unmarshal(payload, &f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+type WriteTest struct {
+	Action
+}
+
+func (this *WriteTest) OnTick(tick *Tick) b3.Status {
+	tick.Blackboard.SetMem("hp", 10)
+	return b3.RUNNING
+}
+
+func TestStream(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("WriteTest", new(WriteTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "ok"},
+			"3": {Id: "3", Name: "WriteTest", Title: "write"},
+		},
+	}, maps)
+	board := NewBlackboard()
+	board.SetMem("name", "npc")
+	other := NewBlackboard()
+
+	server := New()
+	server.Attach("npc", tree, board)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	conn, reader := dialWS(t, addr, "/ws?agent=npc")
+	defer conn.Close()
+	init := readFrame(t, reader)
+	if init.Type != "init" || init.Tree == nil || len(init.Tree.Children) != 2 {
+		t.Fatalf("init frame = %+v", init)
+	}
+	// 黑板在tick的goroutine中读取，第一次tick前为空
+	if len(init.Blackboard) != 0 {
+		t.Errorf("init blackboard before the first tick = %+v", init.Blackboard)
+	}
+
+	tree.Tick(nil, other) // not attached
+	tree.Tick(nil, board)
+	init = readFrame(t, reader)
+	if init.Type != "init" || len(init.Blackboard) != 1 || string(init.Blackboard[0].Value) != `"npc"` {
+		t.Errorf("init frame of the first tick = %+v", init)
+	}
+	f := readFrame(t, reader)
+	if f.Type != "tick" || f.Tick != 1 || f.Status != "RUNNING" {
+		t.Fatalf("tick frame = %+v", f)
+	}
+	want := [][2]string{{"2", "SUCCESS"}, {"3", "RUNNING"}, {"1", "RUNNING"}}
+	if len(f.Nodes) != 3 || f.Nodes[0] != want[0] || f.Nodes[1] != want[1] || f.Nodes[2] != want[2] {
+		t.Errorf("nodes = %v, want %v", f.Nodes, want)
+	}
+	if strings.Join(f.Open, ",") != "1,3" {
+		t.Errorf("open = %v", f.Open)
+	}
+	found := false
+	for _, entry := range f.Blackboard {
+		if entry.Key == "hp" && entry.Tree == "" && string(entry.Value) == "10" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("blackboard changes = %+v, want hp=10", f.Blackboard)
+	}
+
+	tree.Halt(board)
+	f = readFrame(t, reader)
+	if f.Type != "halt" || len(f.Halted) != 1 || f.Halted[0] != "3" {
+		t.Errorf("halt frame = %+v", f)
+	}
+
+	server.Detach("npc")
+	if resp, err := http.Get(ts.URL + "/agents"); err == nil {
+		var list []agentInfo
+		json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if len(list) != 0 {
+			t.Errorf("agents after detach = %v", list)
+		}
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("WriteTest", new(WriteTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "WriteTest", Title: "write"},
+			"3": {Id: "3", Name: "Succeeder", Title: "ok"},
+		},
+	}, maps)
+	board := NewBlackboard()
+	dbg := debugger.New(tree)
+	defer dbg.Close()
+
+	server := New()
+	server.Attach("npc", tree, board)
+	server.AddDebugger(dbg)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	post := func(path string) int {
+		resp, err := http.Post(ts.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// 其他网页发送的请求被拒绝
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/debug/break?agent=npc&node=1", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin break = %v, %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/debug/break?agent=npc&node=2&after=1", nil)
+	req.Header.Set("Origin", ts.URL)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("same-origin break = %v, %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+	if len(dbg.Breakpoints()) != 1 {
+		t.Fatalf("breakpoints = %+v", dbg.Breakpoints())
+	}
+	dbg.ClearBreakpoints()
+	if code := post("/debug/break?agent=npc&node=2&after=1"); code != http.StatusOK {
+		t.Fatalf("break = %d", code)
+	}
+
+	conn, reader := dialWS(t, addr, "/ws?agent=npc")
+	defer conn.Close()
+	readFrame(t, reader) // init
+
+	done := make(chan b3.Status)
+	go func() { done <- tree.Tick(nil, board) }()
+	readFrame(t, reader) // init，第一次tick时读取黑板
+	f := readFrame(t, reader)
+	if f.Type != "pause" || f.Pause == nil || f.Pause.Key != "2" || f.Pause.Status != "RUNNING" {
+		t.Fatalf("pause frame = %+v", f)
+	}
+
+	resp, err := http.Get(ts.URL + "/debug?agent=npc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state debugState
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if state.Pause == nil || len(state.Breakpoints) != 1 {
+		t.Fatalf("debug state = %+v", state)
+	}
+	found := false
+	for _, entry := range state.Blackboard {
+		found = found || (entry.Key == "hp" && string(entry.Value) == "10")
+	}
+	if !found {
+		t.Errorf("paused blackboard = %+v, want hp=10", state.Blackboard)
+	}
+
+	if code := post("/debug/step?agent=npc"); code != http.StatusNoContent {
+		t.Fatalf("step = %d", code)
+	}
+	f = readFrame(t, reader)
+	if f.Type != "pause" || f.Pause.Key != "1" || f.Pause.Breakpoint != 0 {
+		t.Fatalf("step frame = %+v", f.Pause)
+	}
+	post("/debug/continue?agent=npc")
+	if status := <-done; status != b3.RUNNING {
+		t.Errorf("status = %v", status)
+	}
+	if f = readFrame(t, reader); f.Type != "tick" {
+		t.Errorf("frame after continue = %+v", f)
+	}
+	if code := post("/debug/continue?agent=npc"); code != http.StatusConflict {
+		t.Errorf("continue when not paused = %d", code)
+	}
+}
+
+// BigWrite 每次tick写入一个大的值
+type BigWrite struct {
+	Action
+}
+
+func (this *BigWrite) OnTick(tick *Tick) b3.Status {
+	n := tick.Blackboard.GetInt("n", "", "") + 1
+	tick.Blackboard.SetMem("n", n)
+	tick.Blackboard.SetMem("big", strings.Repeat(string(rune('a'+n%26)), 64<<10))
+	return b3.SUCCESS
+}
+
+func TestSlowClient(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("BigWrite", new(BigWrite))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "BigWrite", Title: "big"}},
+	}, maps)
+	board := NewBlackboard()
+	server := New()
+	server.Attach("npc", tree, board)
+	defer server.Detach("npc")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// 握手后不再读取的页面
+	conn, _ := dialWS(t, strings.TrimPrefix(ts.URL, "http://"), "/ws?agent=npc")
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			tree.Tick(nil, board)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticks blocked by a client that does not read")
+	}
+	server.mu.Lock()
+	a := server.agents["npc"]
+	server.mu.Unlock()
+	a.mu.Lock()
+	clients := len(a.clients)
+	a.mu.Unlock()
+	if clients != 0 {
+		t.Errorf("slow client not dropped, %d clients", clients)
+	}
+}
+
+func TestAttachWhileTicking(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("BigWrite", new(BigWrite))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "BigWrite", Title: "big"}},
+	}, maps)
+	board := NewBlackboard()
+	server := New()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				tree.Tick(nil, board)
+			}
+		}
+	}()
+	// 在其他goroutine中attach和detach，用-race检查
+	for i := 0; i < 200; i++ {
+		server.Attach("npc", tree, board)
+		server.Detach("npc")
+	}
+	server.Attach("npc", tree, board)
+	close(stop)
+	<-done
+
+	tree.Tick(nil, board)
+	server.mu.Lock()
+	a := server.agents["npc"]
+	server.mu.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.synced || a.values[bbEntry{Key: "n"}.id()].Value == nil {
+		t.Errorf("agent not synced on the ticking goroutine: %v", a.values)
+	}
+}
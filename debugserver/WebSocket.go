@@ -0,0 +1,186 @@
+package debugserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 最小的WebSocket服务端实现(RFC 6455)，只支持发送文本帧，忽略客户端发来的数据帧
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+
+	// 客户端帧的最大长度，本服务不需要客户端发送数据
+	maxClientFrame = 1 << 16
+
+	// 写一帧的超时，不读数据的页面不会让写一直阻塞
+	writeTimeout = 5 * time.Second
+)
+
+var errBadFrame = errors.New("websocket: bad frame")
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // 保护写
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgrade 完成WebSocket握手，失败时已写入HTTP错误
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade expected", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &wsConn{conn: conn, rw: rw}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// writeFrame 写入一个不分片、不加掩码的帧
+func (this *wsConn) writeFrame(opcode byte, payload []byte) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := this.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := this.rw.Write(payload); err != nil {
+		return err
+	}
+	return this.rw.Flush()
+}
+
+// readFrame 读取客户端的一个帧并去掉掩码
+func (this *wsConn) readFrame() (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(this.rw, header[:]); err != nil {
+		return
+	}
+	opcode = header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		// 客户端的帧必须有掩码
+		return 0, nil, errBadFrame
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(this.rw, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(this.rw, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxClientFrame {
+		return 0, nil, errBadFrame
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(this.rw, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(this.rw, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// readLoop 处理客户端的控制帧，直到连接关闭
+func (this *wsConn) readLoop() {
+	for {
+		opcode, payload, err := this.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opClose:
+			this.writeFrame(opClose, nil)
+			return
+		case opPing:
+			if this.writeFrame(opPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (this *wsConn) Close() error {
+	return this.conn.Close()
+}
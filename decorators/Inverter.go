@@ -25,7 +25,7 @@ type Inverter struct {
 **/
 func (this *Inverter) OnTick(tick *Tick) b3.Status {
 	if this.GetChild() == nil {
-		return b3.ERROR
+		return tick.Fail(ErrNoChild)
 	}
 
 	var status = this.GetChild().Execute(tick)
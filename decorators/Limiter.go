@@ -48,7 +48,7 @@ func (this *Limiter) Initialize(setting *BTNodeCfg) {
 **/
 func (this *Limiter) OnTick(tick *Tick) b3.Status {
 	if this.GetChild() == nil {
-		return b3.ERROR
+		return tick.Fail(ErrNoChild)
 	}
 	var i = tick.Blackboard.GetInt("i", tick.GetTree().GetID(), this.GetID())
 	if i < this.maxLoop {
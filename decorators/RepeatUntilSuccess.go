@@ -58,7 +58,7 @@ func (this *RepeatUntilSuccess) OnOpen(tick *Tick) {
 **/
 func (this *RepeatUntilSuccess) OnTick(tick *Tick) b3.Status {
 	if this.GetChild() == nil {
-		return b3.ERROR
+		return tick.Fail(ErrNoChild)
 	}
 	var i = tick.Blackboard.GetInt("i", tick.GetTree().GetID(), this.GetID())
 	var status = b3.ERROR
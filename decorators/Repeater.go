@@ -59,7 +59,7 @@ func (this *Repeater) OnOpen(tick *Tick) {
 func (this *Repeater) OnTick(tick *Tick) b3.Status {
 	//fmt.Println("tick ", this.GetTitle())
 	if this.GetChild() == nil {
-		return b3.ERROR
+		return tick.Fail(ErrNoChild)
 	}
 	var i = tick.Blackboard.GetInt("i", tick.GetTree().GetID(), this.GetID())
 	var status = b3.SUCCESS
@@ -1,3 +1,3 @@
 module behavior3go
 
-go 1.17
+go 1.18
@@ -43,3 +43,20 @@ func CreateBevTreeFromConfig(config *BTTreeCfg, extMap *b3.RegisterStructMaps) *
 	tree.Load(config, baseMaps, extMap)
 	return tree
 }
+
+// TryCreateBevTreeFromConfig works as CreateBevTreeFromConfig, and returns
+// an error instead of panicking when config is not a valid tree.
+func TryCreateBevTreeFromConfig(config *BTTreeCfg, extMap *b3.RegisterStructMaps) (*BehaviorTree, error) {
+	baseMaps := createBaseStructMaps()
+	tree := NewBeTree()
+	if err := tree.TryLoad(config, baseMaps, extMap); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// IsBaseNode reports whether name is a built-in node, registered by
+// CreateBevTreeFromConfig.
+func IsBaseNode(name string) bool {
+	return createBaseStructMaps().CheckElem(name)
+}
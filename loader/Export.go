@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+)
+
+/**
+ * ExportProject returns the trees as a project exported by the editor (see
+ * `BehaviorTree.Export`). The nodes that are not built-in are declared as
+ * custom nodes, with the properties of their first use, so that the
+ * project opens in the editor. The first tree is selected.
+**/
+func ExportProject(trees ...*BehaviorTree) *BTProjectCfg {
+	project := &BTProjectCfg{
+		Version: EditorVersion,
+		ID:      b3.CreateUUID(),
+		Scope:   "project",
+		Trees:   make([]BTTreeCfg, 0, len(trees)),
+	}
+	custom := make(map[string]CustomNodeCfg)
+	for _, tree := range trees {
+		cfg := tree.Export()
+		project.Trees = append(project.Trees, *cfg)
+		for _, node := range cfg.Nodes {
+			if node.Category == "tree" || IsBaseNode(node.Name) {
+				continue
+			}
+			if _, ok := custom[node.Name]; ok {
+				continue
+			}
+			custom[node.Name] = CustomNodeCfg{
+				Version:    EditorVersion,
+				Scope:      "node",
+				Name:       node.Name,
+				Category:   node.Category,
+				Title:      node.Name,
+				Properties: node.Properties,
+			}
+		}
+	}
+	if len(project.Trees) > 0 {
+		project.Select = project.Trees[0].ID
+	}
+	// 按名字排序，输出稳定
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		project.CustomNodes = append(project.CustomNodes, custom[name])
+	}
+	return project
+}
+
+// ExportRawProject returns the trees as a raw project of the editor, the
+// content of a .b3 file.
+func ExportRawProject(name string, trees ...*BehaviorTree) *RawProjectCfg {
+	return &RawProjectCfg{Name: name, Data: *ExportProject(trees...)}
+}
+
+// WriteRawProject writes the trees as a .b3 file of the editor.
+func WriteRawProject(w io.Writer, name string, trees ...*BehaviorTree) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ExportRawProject(name, trees...))
+}
+
+// SaveRawProject writes the trees to the .b3 file path.
+func SaveRawProject(path, name string, trees ...*BehaviorTree) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteRawProject(file, name, trees...); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
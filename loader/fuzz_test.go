@@ -0,0 +1,146 @@
+package loader
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+)
+
+// 手写的畸形配置，也作为fuzz的种子
+var malformedTrees = []struct {
+	name, json, err string
+}{
+	{"unknown name", `{"root":"1","nodes":{"1":{"id":"1","name":"Nope"}}}`, "Invalid node name:Nope"},
+	{"missing root", `{"root":"9","nodes":{"1":{"id":"1","name":"Succeeder"}}}`, `root "9" not found`},
+	{"own child", `{"root":"1","nodes":{"1":{"id":"1","name":"Sequence","children":["1","2"]},"2":{"id":"2","name":"Succeeder"}}}`, "its own ancestor"},
+	{"unknown child", `{"root":"1","nodes":{"1":{"id":"1","name":"Priority","children":["2","3"]},"2":{"id":"2","name":"Succeeder"}}}`, `child "3" not found`},
+	{"unknown decorator child", `{"root":"1","nodes":{"1":{"id":"1","name":"Inverter","child":"2"}}}`, `child "2" not found`},
+	{"cycle", `{"root":"1","nodes":{"1":{"id":"1","name":"Inverter","child":"2"},"2":{"id":"2","name":"Sequence","children":["1"]}}}`, "its own ancestor"},
+	{"no maxLoop", `{"root":"1","nodes":{"1":{"id":"1","name":"Repeater"}}}`, "maxLoop"},
+	{"bad property", `{"root":"1","nodes":{"1":{"id":"1","name":"Wait","properties":{"milliseconds":"soon"}}}}`, "format not float64"},
+}
+
+func TestMalformedTrees(t *testing.T) {
+	for _, test := range malformedTrees {
+		cfg, err := ParseTreeCfg([]byte(test.json))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := TryCreateBevTreeFromConfig(cfg, nil); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	// 没有root的树可以加载，tick返回ERROR
+	tree, err := TryCreateBevTreeFromConfig(&BTTreeCfg{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := tree.Tick(nil, NewBlackboard()); status != b3.ERROR {
+		t.Errorf("empty tree status = %v, want ERROR", status)
+	}
+}
+
+func TestRecursiveSubTree(t *testing.T) {
+	trees := map[string]*BehaviorTree{}
+	SetSubTreeLoadFunc(func(id string) *BehaviorTree {
+		return trees[id]
+	})
+	defer SetSubTreeLoadFunc(nil)
+	trees["a"] = CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "b", Category: "tree"}},
+	}, nil)
+	trees["b"] = CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "b", Category: "tree"}},
+	}, nil)
+
+	result := trees["a"].TickWithResult(struct{}{}, NewBlackboard())
+	if result.Status != b3.ERROR || result.Err == nil || !strings.Contains(result.Err.Error(), "calls itself") {
+		t.Errorf("status = %v, err = %v", result.Status, result.Err)
+	}
+	// 没有target时返回错误，不panic
+	if status := trees["a"].Tick(nil, NewBlackboard()); status != b3.ERROR {
+		t.Errorf("nil target status = %v, want ERROR", status)
+	}
+}
+
+// fuzzable 节点的循环次数之积，太大时一次tick要执行太久
+func fuzzable(trees ...BTTreeCfg) bool {
+	loops := 1.0
+	for _, tree := range trees {
+		for _, node := range tree.Nodes {
+			if n, ok := node.Properties["maxLoop"].(float64); ok && n > 1 {
+				loops *= n
+			}
+		}
+	}
+	return loops <= 1000
+}
+
+// tickFuzz tick几次再中断，不能panic
+func tickFuzz(tree *BehaviorTree) {
+	board := NewBlackboard()
+	for i := 0; i < 3; i++ {
+		tree.Tick(struct{}{}, board)
+	}
+	tree.Halt(board)
+	tree.TickWithResult(nil, NewBlackboard(), WithTrace())
+}
+
+func addSeeds(f *testing.F, files ...string) {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzLoadTree(f *testing.F) {
+	addSeeds(f, "tree.json", "../examples/load_from_tree/tree.json")
+	for _, test := range malformedTrees {
+		f.Add([]byte(test.json))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, err := ParseTreeCfg(data)
+		if err != nil || !fuzzable(*cfg) {
+			return
+		}
+		tree, err := TryCreateBevTreeFromConfig(cfg, nil)
+		if err != nil {
+			return
+		}
+		tickFuzz(tree)
+	})
+}
+
+func FuzzLoadRawProject(f *testing.F) {
+	addSeeds(f, "../examples/subtree/example.b3", "../examples/memsubtree/memsubtree.b3", "../examples/load_from_rawproject/example.b3")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		project, err := ParseRawProjectCfg(data)
+		if err != nil || !fuzzable(project.Data.Trees...) {
+			return
+		}
+		trees := map[string]*BehaviorTree{}
+		for i := range project.Data.Trees {
+			cfg := &project.Data.Trees[i]
+			if tree, err := TryCreateBevTreeFromConfig(cfg, nil); err == nil {
+				trees[cfg.ID] = tree
+			}
+		}
+		SetSubTreeLoadFunc(func(id string) *BehaviorTree {
+			return trees[id]
+		})
+		defer SetSubTreeLoadFunc(nil)
+		for _, tree := range trees {
+			tickFuzz(tree)
+		}
+	})
+}
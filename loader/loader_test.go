@@ -1,9 +1,12 @@
 package loader
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	b3 "behavior3go"
 	//. "behavior3go/actions"
@@ -70,3 +73,580 @@ func TestLoadTree(t *testing.T) {
 	}
 
 }
+
+///////////////////////端口示例///////////////////////////
+//声明端口的节点：读取target，写出distance
+type PortTest struct {
+	Action
+}
+
+func (this *PortTest) Ports() []Port {
+	return []Port{
+		InputPort[int]("target").Require(),
+		InputPort[float64]("speed").WithDefault(1.0),
+		OutputPort[int]("distance"),
+	}
+}
+
+func (this *PortTest) OnTick(tick *Tick) b3.Status {
+	target, _ := GetInput[int](tick, "target")
+	speed, _ := GetInput[float64](tick, "speed")
+	tick.Output("distance", target*int(speed))
+	return b3.SUCCESS
+}
+
+// HaltPortTest 一直running，halt时读写端口
+type HaltPortTest struct {
+	Action
+}
+
+func (this *HaltPortTest) Ports() []Port {
+	return []Port{
+		InputPort[float64]("speed"),
+		OutputPort[string]("halted"),
+	}
+}
+
+func (this *HaltPortTest) OnTick(tick *Tick) b3.Status {
+	return b3.RUNNING
+}
+
+func (this *HaltPortTest) OnHalt(tick *Tick) {
+	tick.Output("halted", fmt.Sprintf("%T %v", tick.Input("speed"), tick.Input("speed")))
+}
+
+func portTreeCfg(props map[string]interface{}) *BTTreeCfg {
+	return &BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "PortTest", Title: "port", Properties: props},
+		},
+	}
+}
+
+func TestPorts(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("PortTest", new(PortTest))
+
+	tree := CreateBevTreeFromConfig(portTreeCfg(map[string]interface{}{
+		"target": "$enemy", "speed": 3, "distance": "$enemyDistance",
+	}), maps)
+	board := NewBlackboard()
+	board.SetMem("enemy", 5)
+	tree.Tick(nil, board)
+	if v := board.GetInt("enemyDistance", "", ""); v != 15 {
+		t.Errorf("output = %d, want 15", v)
+	}
+
+	report := tree.KeyReport()
+	if len(report) != 1 || fmt.Sprint(report[0].Reads, report[0].Writes) != "[enemy] [enemyDistance]" {
+		t.Errorf("report = %+v", report)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("loading a tree with an unmapped required port should fail")
+		}
+	}()
+	CreateBevTreeFromConfig(portTreeCfg(map[string]interface{}{"speed": 3}), maps)
+}
+
+func TestPortsOnHalt(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("HaltPortTest", new(HaltPortTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "HaltPortTest", Title: "wait", Properties: map[string]interface{}{"speed": 3, "halted": "$halted"}},
+		},
+	}, maps)
+	board := NewBlackboard()
+	tree.Tick(nil, board)
+	tree.Halt(board)
+	// halt时端口属于被halt的节点，常量转换为端口的类型
+	if v := board.GetMem("halted"); v != "float64 3" {
+		t.Errorf("halted = %v, want float64 3", v)
+	}
+}
+
+///////////////////////Halt示例///////////////////////////
+//一直running的节点，记录open/close次数
+type RunningTest struct {
+	Action
+}
+
+func (this *RunningTest) OnOpen(tick *Tick) {
+	tick.GetTarget().(map[string]int)["open"]++
+}
+
+func (this *RunningTest) OnTick(tick *Tick) b3.Status {
+	tick.Blackboard.Set("ticks", tick.Blackboard.GetInt("ticks", tick.GetTree().GetID(), this.GetID())+1, tick.GetTree().GetID(), this.GetID())
+	return b3.RUNNING
+}
+
+func (this *RunningTest) OnHalt(tick *Tick) {
+	tick.GetTarget().(map[string]int)["halt"]++
+}
+
+func (this *RunningTest) OnClose(tick *Tick) {
+	tick.GetTarget().(map[string]int)["close"]++
+}
+
+func TestHalt(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("RunningTest", new(RunningTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "MemSequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "RunningTest", Title: "run"},
+		},
+	}, maps)
+
+	counts := map[string]int{}
+	board := NewBlackboard()
+	tree.Tick(counts, board)
+	tree.Tick(counts, board)
+	tree.Halt(board)
+	if counts["open"] != 1 || counts["halt"] != 1 || counts["close"] != 1 {
+		t.Errorf("after halt: %v, want 1 open, 1 halt and 1 close", counts)
+	}
+	if board.GetInt("ticks", tree.GetID(), "2") != 0 {
+		t.Error("node memory was not reset by Halt")
+	}
+	tree.Halt(board) // nothing left open
+	tree.Tick(counts, board)
+	if counts["open"] != 2 || counts["close"] != 1 {
+		t.Errorf("after restart: %v, want 2 open and 1 close", counts)
+	}
+}
+
+// BranchTest 黑板的branch等于标题时成功
+type BranchTest struct {
+	Condition
+}
+
+func (this *BranchTest) OnTick(tick *Tick) b3.Status {
+	if tick.Blackboard.Get("branch", "", "") == this.GetTitle() {
+		return b3.SUCCESS
+	}
+	return b3.FAILURE
+}
+
+// haltRecorder 记录被中断的节点
+type haltRecorder struct {
+	BaseTickListener
+	halted []string
+}
+
+func (this *haltRecorder) HaltNode(tick *Tick, node IBaseNode) {
+	this.halted = append(this.halted, node.GetTitle())
+}
+
+func TestHaltOnBranchSwitch(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("RunningTest", new(RunningTest))
+	maps.Register("BranchTest", new(BranchTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "5"}},
+			"2": {Id: "2", Name: "Sequence", Title: "seqB", Children: []string{"3", "4"}},
+			"3": {Id: "3", Name: "BranchTest", Title: "b"},
+			"4": {Id: "4", Name: "RunningTest", Title: "runB"},
+			"5": {Id: "5", Name: "Sequence", Title: "seqA", Children: []string{"6"}},
+			"6": {Id: "6", Name: "RunningTest", Title: "runA"},
+		},
+	}, maps)
+	recorder := &haltRecorder{}
+	tree.AddListener(recorder)
+
+	counts := map[string]int{}
+	board := NewBlackboard()
+	tree.Tick(counts, board)
+	if path := fmt.Sprint(tree.RunningPath(board)); path != "[root(1) seqA(5) runA(6)]" {
+		t.Fatalf("path = %s", path)
+	}
+	// seqA不再执行：它和runA都被中断，仍在运行的root保持open
+	board.SetMem("branch", "b")
+	tree.Tick(counts, board)
+	if fmt.Sprint(recorder.halted) != "[runA seqA]" {
+		t.Errorf("halted = %v, want [runA seqA]", recorder.halted)
+	}
+	if path := fmt.Sprint(tree.RunningPath(board)); path != "[root(1) seqB(2) runB(4)]" {
+		t.Errorf("path = %s", path)
+	}
+	if counts["open"] != 2 || counts["halt"] != 1 || counts["close"] != 1 {
+		t.Errorf("counts = %v, want 2 open, 1 halt and 1 close", counts)
+	}
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestHaltOnTimeout(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("RunningTest", new(RunningTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "MaxTime", Title: "timeout", Child: "2", Properties: map[string]interface{}{"maxTime": 10.0}},
+			"2": {Id: "2", Name: "RunningTest", Title: "run"},
+		},
+	}, maps)
+	clock := &testClock{time.Unix(0, 0)}
+	tree.SetClock(clock)
+
+	counts := map[string]int{}
+	board := NewBlackboard()
+	if status := tree.Tick(counts, board); status != b3.RUNNING {
+		t.Fatalf("first tick = %v, want RUNNING", status)
+	}
+	clock.now = clock.now.Add(20 * time.Millisecond)
+	if status := tree.Tick(counts, board); status != b3.FAILURE {
+		t.Fatalf("second tick = %v, want FAILURE", status)
+	}
+	if counts["halt"] != 1 || counts["close"] != 1 {
+		t.Errorf("after timeout: %v, want the child halted once", counts)
+	}
+}
+
+///////////////////////Panic示例///////////////////////////
+type PanicTest struct {
+	Action
+}
+
+func (this *PanicTest) OnTick(tick *Tick) b3.Status {
+	panic("boom")
+}
+
+func TestPanicPolicy(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("PanicTest", new(PanicTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "PanicTest", Title: "panic"},
+			"3": {Id: "3", Name: "Succeeder", Title: "ok"},
+		},
+	}, maps)
+
+	var handled []*NodePanic
+	tree.SetPanicPolicy(&PanicPolicy{
+		DisableNode: true,
+		Handler: func(tick *Tick, p *NodePanic) {
+			handled = append(handled, p)
+		},
+	})
+
+	board := NewBlackboard()
+	for i := 0; i < 3; i++ {
+		// Priority stops at the ERROR of the panicking child
+		if status := tree.Tick(nil, board); status != b3.ERROR {
+			t.Fatalf("tick %d = %v, want ERROR", i, status)
+		}
+	}
+	if len(handled) != 1 || handled[0].Value != "boom" || handled[0].Node.GetID() != "2" {
+		t.Fatalf("handled = %v, want one panic of node 2", handled)
+	}
+	if _, ok := handled[0].Node.(*PanicTest); !ok {
+		t.Errorf("panic node is %T, want *PanicTest", handled[0].Node)
+	}
+
+	tree.Halt(board) // re-enables the node
+	tree.Tick(nil, board)
+	if len(handled) != 2 {
+		t.Errorf("node should run again after Halt, %d panics", len(handled))
+	}
+}
+
+func TestTickError(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "Inverter", Title: "inv"},
+		},
+	}, nil)
+
+	result := tree.TickWithResult(nil, NewBlackboard())
+	if result.Status != b3.ERROR {
+		t.Fatalf("status = %v, want ERROR", result.Status)
+	}
+	if !errors.Is(result.Err, ErrNoChild) {
+		t.Fatalf("err = %v, want ErrNoChild", result.Err)
+	}
+	if msg := result.Err.Error(); msg != "root(1)/inv(2): decorator has no child" {
+		t.Errorf("err = %q", msg)
+	}
+}
+
+func TestTickResult(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("RunningTest", new(RunningTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "ok"},
+			"3": {Id: "3", Name: "RunningTest", Title: "run"},
+		},
+	}, maps)
+
+	result := tree.TickWithResult(map[string]int{}, NewBlackboard(), WithTrace())
+	if result.Status != b3.RUNNING || result.NodeCount != 3 {
+		t.Fatalf("status = %v, nodes = %d, want RUNNING and 3", result.Status, result.NodeCount)
+	}
+	if _, ok := result.Running.(*RunningTest); !ok {
+		t.Errorf("running node is %T, want *RunningTest", result.Running)
+	}
+	if path := fmt.Sprint(result.RunningPath); path != "[root(1) run(3)]" {
+		t.Errorf("running path = %s", path)
+	}
+	want := []TraceEntry{
+		{Node: NodeInfo{ID: "1", Name: "Sequence", Title: "root", Category: b3.COMPOSITE}, Depth: 0, Status: b3.RUNNING},
+		{Node: NodeInfo{ID: "2", Name: "Succeeder", Title: "ok", Category: b3.ACTION}, Depth: 1, Status: b3.SUCCESS},
+		{Node: NodeInfo{ID: "3", Name: "RunningTest", Title: "run", Category: b3.ACTION}, Depth: 1, Status: b3.RUNNING},
+	}
+	if !reflect.DeepEqual(result.Trace, want) {
+		t.Errorf("trace = %v, want %v", result.Trace, want)
+	}
+}
+
+// WriteTest 写入全局key target和note
+type WriteTest struct {
+	Action
+}
+
+func (this *WriteTest) OnTick(tick *Tick) b3.Status {
+	tick.Blackboard.SetMem("target", 1)
+	tick.Blackboard.SetMem("note", "written")
+	tick.Blackboard.SetMem("seen", tick.Blackboard.GetMem("count"))
+	return b3.SUCCESS
+}
+
+func TestSubTreePorts(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("WriteTest", new(WriteTest))
+	sub := CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:    "writer",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "WriteTest", Title: "write"}},
+	}, maps)
+	SetSubTreeLoadFunc(func(id string) *BehaviorTree { return sub })
+	defer SetSubTreeLoadFunc(nil)
+	main := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "writer", Title: "call", Category: "tree",
+				Properties: map[string]interface{}{"target": "$enemy", "note": "a comment", "count": 3}},
+		},
+	}, maps)
+
+	board := NewBlackboard()
+	main.Tick(map[string]int{}, board)
+	if v := board.GetMem("enemy"); v != 1 {
+		t.Errorf("mapped write = %v, want 1", v)
+	}
+	// 不以$开头的字符串不是端口，子树写入自己的key
+	if v := board.GetMem("note"); v != "written" {
+		t.Errorf("unmapped write = %v, want written", v)
+	}
+	// 其他常量是端口
+	if v := board.GetMem("seen"); v != 3 {
+		t.Errorf("constant port read = %v, want 3", v)
+	}
+	if ports := main.GetRoot().(*SubTree).GetPorts(); len(ports) != 2 || ports["count"] != 3 {
+		t.Errorf("ports = %v", ports)
+	}
+}
+
+func TestRunningPath(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("RunningTest", new(RunningTest))
+	trees := map[string]*BehaviorTree{}
+	SetSubTreeLoadFunc(func(id string) *BehaviorTree {
+		return trees[id]
+	})
+	defer SetSubTreeLoadFunc(nil)
+	trees["inner"] = CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:   "inner",
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "RunningTest", Title: "run"},
+		},
+	}, maps)
+	trees["middle"] = CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:   "middle",
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "seq", Children: []string{"2"}},
+			"2": {Id: "2", Name: "inner", Title: "callInner", Category: "tree"},
+		},
+	}, maps)
+	main := CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:   "main",
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "middle", Title: "callMiddle", Category: "tree"},
+		},
+	}, maps)
+
+	board := NewBlackboard()
+	if path := main.RunningPath(board); len(path) != 0 {
+		t.Fatalf("path before tick = %v, want empty", path)
+	}
+	counts := map[string]int{}
+	main.Tick(counts, board)
+	main.Tick(counts, board)
+	path := main.RunningPath(board)
+	if fmt.Sprint(path) != "[callMiddle(1) seq(1) callInner(2) run(1)]" {
+		t.Fatalf("path = %v", path)
+	}
+	if !path[0].IsSubTree || path[0].Name != "middle" || !path[2].IsSubTree || path[3].IsSubTree {
+		t.Errorf("subtree boundaries = %+v", path)
+	}
+	// 三棵树中ID为1的节点各自open，状态和内存按Tick.NodeKey区分
+	if counts["open"] != 1 {
+		t.Errorf("opened %d times, want 1", counts["open"])
+	}
+	if v := board.GetInt("ticks", main.GetID(), "1/2/1"); v != 2 {
+		t.Errorf("node memory of run = %d, want 2", v)
+	}
+	main.Halt(board)
+	if path := main.RunningPath(board); len(path) != 0 {
+		t.Errorf("path after halt = %v, want empty", path)
+	}
+	if counts["halt"] != 1 || counts["close"] != 1 {
+		t.Errorf("after halt: %v, want 1 halt and 1 close", counts)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Succeeder", Title: "flagged"},
+			"3": {Id: "3", Name: "Runner", Title: "run"},
+		},
+	}, nil)
+
+	var calls []string
+	logger := func(name string) Interceptor {
+		return func(next ExecFunc) ExecFunc {
+			return func(tick *Tick, node IBaseNode) b3.Status {
+				calls = append(calls, name+">"+node.GetTitle())
+				status := next(tick, node)
+				calls = append(calls, name+"<"+node.GetTitle())
+				return status
+			}
+		}
+	}
+	// 关闭的节点不执行，返回FAILURE
+	flags := func(next ExecFunc) ExecFunc {
+		return func(tick *Tick, node IBaseNode) b3.Status {
+			if node.GetTitle() == "flagged" {
+				return b3.FAILURE
+			}
+			return next(tick, node)
+		}
+	}
+	UseInterceptor(logger("global"))
+	defer ClearInterceptors()
+	tree.Use(logger("tree"), flags)
+
+	result := tree.TickWithResult(nil, NewBlackboard(), WithTrace())
+	if result.Status != b3.RUNNING {
+		t.Fatalf("status = %v, want RUNNING", result.Status)
+	}
+	want := []string{
+		"global>root", "tree>root",
+		"global>flagged", "tree>flagged", "tree<flagged", "global<flagged",
+		"global>run", "tree>run", "tree<run", "global<run",
+		"tree<root", "global<root",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	// the flagged node was not entered
+	if len(result.Trace) != 2 || result.Trace[1].Node.Title != "run" {
+		t.Errorf("trace = %v", result.Trace)
+	}
+}
+
+func TestExport(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("LogTest", new(LogTest))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:    "main",
+		Title: "main tree",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2", "4"}},
+			"2": {Id: "2", Name: "Inverter", Title: "not", Child: "3"},
+			"3": {Id: "3", Name: "LogTest", Title: "log", Properties: map[string]interface{}{"info": "hello"}},
+			"4": {Id: "4", Name: "sub", Title: "call", Category: "tree", Properties: map[string]interface{}{"target": "$enemy"}},
+		},
+	}, maps)
+	// 运行时修改：改标题，加入另一棵树的节点(ID冲突)
+	tree.GetRoot().(interface{ SetTitle(string) }).SetTitle("main root")
+	added := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "added"}},
+	}, nil).GetRoot()
+	tree.GetRoot().(IComposite).AddChild(added)
+
+	var buf bytes.Buffer
+	if err := WriteRawProject(&buf, "exported", tree); err != nil {
+		t.Fatal(err)
+	}
+	project, err := ParseRawProjectCfg(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "exported" || len(project.Data.Trees) != 1 || project.Data.Select != "main" {
+		t.Fatalf("project = %+v", project)
+	}
+	if custom := project.Data.CustomNodes; len(custom) != 1 || custom[0].Name != "LogTest" || custom[0].Category != b3.ACTION {
+		t.Errorf("custom nodes = %+v", custom)
+	}
+	cfg := &project.Data.Trees[0]
+	root := cfg.Nodes[cfg.Root]
+	if cfg.ID != "main" || root.Title != "main root" || len(root.Children) != 3 || root.Display == nil || root.Display.Y != 0 {
+		t.Fatalf("tree = %+v, root = %+v", cfg, root)
+	}
+	if added := cfg.Nodes[root.Children[2]]; added.Id == "1" || added.Title != "added" {
+		t.Errorf("added node = %+v", added)
+	}
+	if call := cfg.Nodes["4"]; call.Category != "tree" || call.Properties["target"] != "$enemy" {
+		t.Errorf("subtree node = %+v", call)
+	}
+	// 叶子从上到下排列，子节点在父节点右边
+	log, not := cfg.Nodes["3"], cfg.Nodes["2"]
+	if log.Properties["info"] != "hello" || log.Display.X <= not.Display.X || log.Display.Y >= cfg.Nodes["4"].Display.Y {
+		t.Errorf("log = %+v %+v, not = %+v", log, log.Display, not.Display)
+	}
+
+	// 导出的树可以重新加载
+	reloaded, err := TryCreateBevTreeFromConfig(cfg, maps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	reloaded.Walk(func(node IBaseNode, depth int) bool {
+		titles = append(titles, node.GetTitle())
+		return true
+	})
+	if fmt.Sprint(titles) != "[main root not log call added]" {
+		t.Errorf("reloaded = %v", titles)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Package metrics measures the execution of the nodes of behavior trees.
+
+Metrics are opt-in, per tree:
+
+	collector := metrics.New()
+	collector.Attach(tree)
+	http.Handle("/metrics", collector) // Prometheus text format
+
+For every node the collector counts the ticks and the returned statuses, and
+measures the time spent in `OnTick`: the total, including the children, the
+self time, excluding the children, and the longest tick. Nodes are
+identified by `core.Tick.NodeKey`: the nodes of a subtree are measured
+separately for each `tree` node calling it.
+
+The times are measured with the wall clock, not the tree clock.
+*/
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+// NodeStats are the metrics of one node.
+type NodeStats struct {
+	Tree     string // title of the tree, or its ID
+	TreeID   string // ID of the config of the tree, see core.BehaviorTree.GetCfgID
+	Key      string // see core.Tick.NodeKey
+	ID       string
+	Name     string
+	Title    string
+	Category string
+
+	Ticks   uint64
+	Success uint64
+	Failure uint64
+	Running uint64
+	Error   uint64
+	// Total is the time spent in OnTick, including the children.
+	Total time.Duration
+	// Self is Total without the time spent in the children.
+	Self time.Duration
+	// Max is the longest tick, including the children.
+	Max time.Duration
+}
+
+// Count returns the number of ticks that returned status.
+func (this *NodeStats) Count(status b3.Status) uint64 {
+	switch status {
+	case b3.SUCCESS:
+		return this.Success
+	case b3.FAILURE:
+		return this.Failure
+	case b3.RUNNING:
+		return this.Running
+	case b3.ERROR:
+		return this.Error
+	}
+	return 0
+}
+
+// Collector collects the metrics of the attached trees. It is safe for
+// concurrent use: the trees can be ticked on several goroutines, and
+// attached or detached while they tick. Ticking takes no lock shared by the
+// agents: the counters are atomic and the timings are kept on the tick.
+type Collector struct {
+	trees TreeListeners[*treeMetrics]
+}
+
+// treeMetrics 一棵树的listener
+type treeMetrics struct {
+	BaseTickListener
+	tree *BehaviorTree
+	// 节点key对应的*nodeCounters
+	nodes sync.Map
+}
+
+// nodeCounters 一个节点的计数，原子操作
+type nodeCounters struct {
+	ticks, success, failure, running, errors uint64
+	total, self, max                         int64 // time.Duration
+	stats                                    NodeStats
+}
+
+// timing 本次tick中正在执行OnTick的节点，保存在tick上(见Tick.SetValue)
+type timing struct {
+	node     IBaseNode
+	counters *nodeCounters
+	start    time.Time
+	children time.Duration
+}
+
+// timingKey tick上timing栈的key
+type timingKey struct {
+	tree *treeMetrics
+}
+
+func New() *Collector {
+	return &Collector{}
+}
+
+// Attach starts collecting the metrics of tree.
+func (this *Collector) Attach(tree *BehaviorTree) {
+	this.trees.Attach(tree, func() *treeMetrics { return &treeMetrics{tree: tree} })
+}
+
+// Detach stops collecting the metrics of tree and drops them.
+func (this *Collector) Detach(tree *BehaviorTree) {
+	this.trees.Detach(tree)
+}
+
+// Reset clears the metrics collected so far.
+func (this *Collector) Reset() {
+	this.trees.Each(func(_ *BehaviorTree, m *treeMetrics) {
+		m.nodes.Range(func(key, _ interface{}) bool {
+			m.nodes.Delete(key)
+			return true
+		})
+	})
+}
+
+// Snapshot returns a copy of the metrics, sorted by tree and node key.
+func (this *Collector) Snapshot() []NodeStats {
+	var stats []NodeStats
+	this.trees.Each(func(_ *BehaviorTree, m *treeMetrics) {
+		m.nodes.Range(func(_, value interface{}) bool {
+			stats = append(stats, value.(*nodeCounters).snapshot())
+			return true
+		})
+	})
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Tree != stats[j].Tree {
+			return stats[i].Tree < stats[j].Tree
+		}
+		if stats[i].TreeID != stats[j].TreeID {
+			return stats[i].TreeID < stats[j].TreeID
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// Slowest returns the n nodes with the largest self time.
+func (this *Collector) Slowest(n int) []NodeStats {
+	stats := this.Snapshot()
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Self > stats[j].Self })
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+func (this *nodeCounters) snapshot() NodeStats {
+	s := this.stats
+	s.Ticks = atomic.LoadUint64(&this.ticks)
+	s.Success = atomic.LoadUint64(&this.success)
+	s.Failure = atomic.LoadUint64(&this.failure)
+	s.Running = atomic.LoadUint64(&this.running)
+	s.Error = atomic.LoadUint64(&this.errors)
+	s.Total = time.Duration(atomic.LoadInt64(&this.total))
+	s.Self = time.Duration(atomic.LoadInt64(&this.self))
+	s.Max = time.Duration(atomic.LoadInt64(&this.max))
+	return s
+}
+
+func (this *nodeCounters) add(status b3.Status, elapsed, self time.Duration) {
+	atomic.AddUint64(&this.ticks, 1)
+	switch status {
+	case b3.SUCCESS:
+		atomic.AddUint64(&this.success, 1)
+	case b3.FAILURE:
+		atomic.AddUint64(&this.failure, 1)
+	case b3.RUNNING:
+		atomic.AddUint64(&this.running, 1)
+	case b3.ERROR:
+		atomic.AddUint64(&this.errors, 1)
+	}
+	atomic.AddInt64(&this.total, int64(elapsed))
+	atomic.AddInt64(&this.self, int64(self))
+	for {
+		max := atomic.LoadInt64(&this.max)
+		if int64(elapsed) <= max || atomic.CompareAndSwapInt64(&this.max, max, int64(elapsed)) {
+			break
+		}
+	}
+}
+
+func (this *treeMetrics) _counters(tick *Tick, node IBaseNode) *nodeCounters {
+	key := tick.NodeKey(node)
+	if c, ok := this.nodes.Load(key); ok {
+		return c.(*nodeCounters)
+	}
+	c := &nodeCounters{stats: NodeStats{
+		Tree:     this.tree.GetDisplayName(),
+		TreeID:   this.tree.GetCfgID(),
+		Key:      key,
+		ID:       node.GetID(),
+		Name:     node.GetName(),
+		Title:    node.GetTitle(),
+		Category: node.GetCategory(),
+	}}
+	if _, isSubTree := node.(*SubTree); isSubTree {
+		c.stats.Category = "tree"
+	}
+	actual, _ := this.nodes.LoadOrStore(key, c)
+	return actual.(*nodeCounters)
+}
+
+func (this *treeMetrics) TickNode(tick *Tick, node IBaseNode) {
+	key := timingKey{this}
+	stack, _ := tick.Value(key).([]*timing)
+	t := &timing{node: node, counters: this._counters(tick, node)}
+	tick.SetValue(key, append(stack, t))
+	t.start = time.Now()
+}
+
+func (this *treeMetrics) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	end := time.Now()
+	key := timingKey{this}
+	stack, _ := tick.Value(key).([]*timing)
+	// 弹出到node为止：panic展开的节点没有TickedNode
+	for len(stack) > 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if t.node != node {
+			continue
+		}
+		elapsed := end.Sub(t.start)
+		t.counters.add(status, elapsed, elapsed-t.children)
+		if len(stack) > 0 {
+			stack[len(stack)-1].children += elapsed
+		}
+		break
+	}
+	tick.SetValue(key, stack)
+}
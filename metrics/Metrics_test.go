@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+)
+
+type SlowCondition struct {
+	Condition
+}
+
+func (this *SlowCondition) OnTick(tick *Tick) b3.Status {
+	time.Sleep(2 * time.Millisecond)
+	return b3.FAILURE
+}
+
+type PanicAction struct {
+	Action
+}
+
+func (this *PanicAction) OnTick(tick *Tick) b3.Status {
+	panic("boom")
+}
+
+func TestCollector(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("SlowCondition", new(SlowCondition))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		ID:    "guard-1",
+		Title: "guard",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Priority", Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "SlowCondition", Title: "see \"enemy\""},
+			"3": {Id: "3", Name: "Succeeder", Title: "idle"},
+		},
+	}, maps)
+
+	collector := New()
+	collector.Attach(tree)
+	board := NewBlackboard()
+	for i := 0; i < 3; i++ {
+		tree.Tick(nil, board)
+	}
+	collector.Detach(tree)
+	tree.Tick(nil, board) // not collected
+
+	// Detach drops the metrics: collect again
+	collector.Attach(tree)
+	for i := 0; i < 3; i++ {
+		tree.Tick(nil, board)
+	}
+	stats := collector.Snapshot()
+	if len(stats) != 3 {
+		t.Fatalf("%d nodes, want 3", len(stats))
+	}
+	root, slow, idle := stats[0], stats[1], stats[2]
+	if root.Ticks != 3 || root.Success != 3 || slow.Failure != 3 || idle.Success != 3 {
+		t.Errorf("counts = %+v", stats)
+	}
+	if slow.Max < 2*time.Millisecond || slow.Self != slow.Total {
+		t.Errorf("slow condition times = %+v", slow)
+	}
+	if root.Total < slow.Total || root.Self >= slow.Self {
+		t.Errorf("root total %v self %v, condition %v", root.Total, root.Self, slow.Total)
+	}
+	if top := collector.Slowest(1); len(top) != 1 || top[0].Key != "2" {
+		t.Errorf("slowest = %+v", top)
+	}
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE b3_node_ticks_total counter",
+		`b3_node_ticks_total{tree="guard",tree_id="guard-1",node="2",name="SlowCondition",title="see \"enemy\"",category="condition"} 3`,
+		`b3_node_status_total{tree="guard",tree_id="guard-1",node="2",name="SlowCondition",title="see \"enemy\"",category="condition",status="failure"} 3`,
+		"# TYPE b3_node_tick_seconds_max gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+
+	collector.Reset()
+	if len(collector.Snapshot()) != 0 {
+		t.Error("Reset kept the metrics")
+	}
+}
+
+func TestCollectorPanic(t *testing.T) {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("PanicAction", new(PanicAction))
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: "Sequence", Title: "root", Children: []string{"2"}},
+			"2": {Id: "2", Name: "PanicAction", Title: "boom"},
+		},
+	}, maps)
+	collector := New()
+	collector.Attach(tree)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("no panic")
+			}
+		}()
+		tree.Tick(nil, NewBlackboard())
+	}()
+	// panic展开的节点没有计数，之后的tick正常计数
+	for _, s := range collector.Snapshot() {
+		if s.Ticks != 0 {
+			t.Errorf("unwound node counted: %+v", s)
+		}
+	}
+	good := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "ok"}},
+	}, maps)
+	collector.Attach(good)
+	good.Tick(nil, NewBlackboard())
+	ok := 0
+	for _, s := range collector.Snapshot() {
+		if s.Title == "ok" {
+			ok += int(s.Ticks)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("ok ticked %d times, want 1", ok)
+	}
+}
+
+func TestCollectorConcurrent(t *testing.T) {
+	tree := CreateBevTreeFromConfig(&BTTreeCfg{
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "idle"}},
+	}, b3.NewRegisterStructMaps())
+	collector := New()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		board := NewBlackboard()
+		for i := 0; i < 500; i++ {
+			tree.Tick(nil, board)
+		}
+	}()
+	// 其他goroutine tick时Attach和Detach
+	for i := 0; i < 100; i++ {
+		collector.Attach(tree)
+		collector.Snapshot()
+		collector.Detach(tree)
+	}
+	wg.Wait()
+}
+
+func TestSameTitle(t *testing.T) {
+	load := func(id string) *BehaviorTree {
+		return CreateBevTreeFromConfig(&BTTreeCfg{
+			ID:    id,
+			Title: "guard",
+			Root:  "1",
+			Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Succeeder", Title: "idle"}},
+		}, b3.NewRegisterStructMaps())
+	}
+	collector := New()
+	for _, tree := range []*BehaviorTree{load("a"), load("b")} {
+		collector.Attach(tree)
+		tree.Tick(nil, NewBlackboard())
+	}
+	// 同名的树是不同的序列
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, id := range []string{"a", "b"} {
+		line := `b3_node_ticks_total{tree="guard",tree_id="` + id + `",node="1",name="Succeeder",title="idle",category="action"} 1`
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, rec.Body.String())
+		}
+	}
+}
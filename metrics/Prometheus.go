@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	b3 "behavior3go"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(s *NodeStats) string {
+	return fmt.Sprintf(`tree="%s",tree_id="%s",node="%s",name="%s",title="%s",category="%s"`,
+		labelEscaper.Replace(s.Tree), labelEscaper.Replace(s.TreeID), labelEscaper.Replace(s.Key),
+		labelEscaper.Replace(s.Name), labelEscaper.Replace(s.Title), labelEscaper.Replace(s.Category))
+}
+
+/**
+ * WritePrometheus writes the metrics in the Prometheus text exposition
+ * format:
+ *
+ *     b3_node_ticks_total           counter, by node
+ *     b3_node_status_total          counter, by node and status
+ *     b3_node_tick_seconds_total    counter, time in OnTick with the children
+ *     b3_node_self_seconds_total    counter, time in OnTick without the children
+ *     b3_node_tick_seconds_max      gauge, longest tick
+ *
+ * Every series has the labels tree (the title), tree_id (the config ID, to
+ * tell apart trees with the same title), node (the node key), name, title
+ * and category.
+**/
+func (this *Collector) WritePrometheus(w io.Writer) error {
+	stats := this.Snapshot()
+	out := bufio.NewWriter(w)
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	header("b3_node_ticks_total", "counter", "Number of ticks of the node.")
+	for i := range stats {
+		fmt.Fprintf(out, "b3_node_ticks_total{%s} %d\n", labels(&stats[i]), stats[i].Ticks)
+	}
+	header("b3_node_status_total", "counter", "Number of ticks of the node by returned status.")
+	for i := range stats {
+		for _, status := range []b3.Status{b3.SUCCESS, b3.FAILURE, b3.RUNNING, b3.ERROR} {
+			fmt.Fprintf(out, "b3_node_status_total{%s,status=\"%s\"} %d\n",
+				labels(&stats[i]), strings.ToLower(status.String()), stats[i].Count(status))
+		}
+	}
+	header("b3_node_tick_seconds_total", "counter", "Time spent in OnTick, including the children.")
+	for i := range stats {
+		fmt.Fprintf(out, "b3_node_tick_seconds_total{%s} %g\n", labels(&stats[i]), stats[i].Total.Seconds())
+	}
+	header("b3_node_self_seconds_total", "counter", "Time spent in OnTick, excluding the children.")
+	for i := range stats {
+		fmt.Fprintf(out, "b3_node_self_seconds_total{%s} %g\n", labels(&stats[i]), stats[i].Self.Seconds())
+	}
+	header("b3_node_tick_seconds_max", "gauge", "Longest tick of the node, including the children.")
+	for i := range stats {
+		fmt.Fprintf(out, "b3_node_tick_seconds_max{%s} %g\n", labels(&stats[i]), stats[i].Max.Seconds())
+	}
+	return out.Flush()
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (this *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	this.WritePrometheus(w)
+}
@@ -0,0 +1,173 @@
+package record
+
+import (
+	b3 "behavior3go"
+	. "behavior3go/core"
+)
+
+/**
+ * Recorder records the ticks of a tree for one blackboard, from its creation
+ * to `Stop`. It must be created, stopped and read on the goroutine ticking
+ * the tree.
+ *
+ * The writes in the node contexts are not recorded: they are the execution
+ * state of the nodes, rebuilt by the replay.
+**/
+type Recorder struct {
+	BaseTickListener
+	tree  *BehaviorTree
+	bb    *Blackboard
+	watch WatchID
+	rec   *Recording
+
+	known map[string]bool
+	// 正在执行的节点，写入归属于栈顶的节点
+	stack  []string
+	inTick bool
+	// 正在记录的帧：tick中，或者BehaviorTree.Halt之后直到下一次写入或tick
+	current *Frame
+	input   []Write
+}
+
+// NewRecorder starts recording the ticks of tree for the agent owning
+// blackboard.
+func NewRecorder(tree *BehaviorTree, blackboard *Blackboard) *Recorder {
+	r := &Recorder{
+		tree:  tree,
+		bb:    blackboard,
+		known: make(map[string]bool),
+		rec:   &Recording{Version: Version, Tree: tree.GetID(), Title: tree.GetTitile()},
+	}
+	add := func(tree string) func(string, interface{}) bool {
+		return func(key string, value interface{}) bool {
+			r.rec.Start = append(r.rec.Start, Write{Key: key, Tree: tree, Value: EncodeValue(value)})
+			return true
+		}
+	}
+	blackboard.Range(add(""))
+	blackboard.Range(add(tree.GetID()), tree.GetID())
+
+	r.watch = blackboard.WatchAll(r.onChange)
+	tree.AddListener(r)
+	return r
+}
+
+// Stop stops recording.
+func (this *Recorder) Stop() {
+	this.tree.RemoveListener(this)
+	this.bb.Unwatch(this.watch)
+}
+
+// Recording returns what was recorded so far.
+func (this *Recorder) Recording() *Recording {
+	return this.rec
+}
+
+func (this *Recorder) onChange(change BlackboardChange) {
+	if change.NodeScope != "" {
+		return
+	}
+	write := Write{Key: change.Key, Tree: change.TreeScope, Removed: change.Removed}
+	if !change.Removed {
+		write.Value = EncodeValue(change.New)
+	}
+	if !this.inTick {
+		// tick之外的写入(包括halt时节点的写入)是下一帧的输入
+		this.current = nil
+		this.input = append(this.input, write)
+		return
+	}
+	node := ""
+	if len(this.stack) > 0 {
+		node = this.stack[len(this.stack)-1]
+	}
+	this.current.Events = append(this.current.Events, Event{Kind: WriteEvent, Node: node, Write: &write})
+}
+
+func (this *Recorder) mine(tick *Tick) bool {
+	return tick.Blackboard.Base() == this.bb
+}
+
+// _event 记录节点事件；在tick之外(BehaviorTree.Halt)时记录到halt帧
+func (this *Recorder) _event(tick *Tick, kind EventKind, node IBaseNode, status b3.Status) string {
+	key := tick.NodeKey(node)
+	if !this.known[key] {
+		this.known[key] = true
+		this.rec.Nodes = append(this.rec.Nodes, NodeMeta{key, node.GetName(), node.GetTitle()})
+	}
+	if this.current == nil {
+		this._startFrame(tick, true)
+	}
+	this.current.Events = append(this.current.Events, Event{Kind: kind, Node: key, Status: status})
+	return key
+}
+
+func (this *Recorder) _startFrame(tick *Tick, halt bool) {
+	this.rec.Frames = append(this.rec.Frames, Frame{Time: tick.Now().UnixNano(), Halt: halt, Input: this.input})
+	this.current = &this.rec.Frames[len(this.rec.Frames)-1]
+	this.input = nil
+}
+
+func (this *Recorder) TickStart(tick *Tick) {
+	if !this.mine(tick) {
+		return
+	}
+	this.inTick = true
+	this.stack = this.stack[:0]
+	this._startFrame(tick, false)
+}
+
+func (this *Recorder) TickEnd(tick *Tick, status b3.Status) {
+	if !this.mine(tick) || this.current == nil {
+		return
+	}
+	this.current.Status = status
+	this.current = nil
+	this.inTick = false
+}
+
+func (this *Recorder) EnterNode(tick *Tick, node IBaseNode) {
+	if this.mine(tick) {
+		this.stack = append(this.stack, this._event(tick, EnterEvent, node, 0))
+	}
+}
+
+func (this *Recorder) OpenNode(tick *Tick, node IBaseNode) {
+	if this.mine(tick) {
+		this._event(tick, OpenEvent, node, 0)
+	}
+}
+
+func (this *Recorder) TickNode(tick *Tick, node IBaseNode) {
+	if this.mine(tick) {
+		this._event(tick, TickEvent, node, 0)
+	}
+}
+
+func (this *Recorder) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	if this.mine(tick) {
+		this._event(tick, TickedEvent, node, status)
+	}
+}
+
+func (this *Recorder) CloseNode(tick *Tick, node IBaseNode) {
+	if this.mine(tick) {
+		this._event(tick, CloseEvent, node, 0)
+	}
+}
+
+func (this *Recorder) HaltNode(tick *Tick, node IBaseNode) {
+	if this.mine(tick) {
+		this._event(tick, HaltEvent, node, 0)
+	}
+}
+
+func (this *Recorder) ExitNode(tick *Tick, node IBaseNode) {
+	if !this.mine(tick) {
+		return
+	}
+	this._event(tick, ExitEvent, node, 0)
+	if len(this.stack) > 0 {
+		this.stack = this.stack[:len(this.stack)-1]
+	}
+}
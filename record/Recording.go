@@ -0,0 +1,187 @@
+/*
+Package record records what happens to one agent in a behavior tree, and
+replays it later against the same tree, without the game.
+
+A Recorder listens to the ticks of a tree for one blackboard and stores
+every node event (enter, open, tick, close, halt, exit) with the statuses,
+and every blackboard write, with the node that made it:
+
+	recorder := record.NewRecorder(tree, board)
+	... // game loop
+	recorder.Stop()
+	recorder.Recording().SaveFile("npc1.b3rec")
+
+The file is gzipped JSON. A Replayer loads the same tree configuration with
+the leaf nodes (actions and conditions) replaced by stubs that return the
+recorded statuses and redo the recorded writes, while the composites and
+decorators run for real. Each frame is ticked with the recorded time and
+the blackboard writes made by the game between ticks, and the events of the
+replay are compared to the recording: the first difference is reported as
+a Divergence. The `cmd/b3replay` command replays a file from the shell.
+*/
+package record
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	b3 "behavior3go"
+)
+
+// Version of the recording format.
+const Version = 1
+
+// EventKind is the kind of a recorded event.
+type EventKind uint8
+
+const (
+	EnterEvent EventKind = iota + 1
+	OpenEvent
+	TickEvent
+	TickedEvent
+	CloseEvent
+	HaltEvent
+	ExitEvent
+	// WriteEvent is a blackboard write made by a node.
+	WriteEvent
+)
+
+var eventNames = [...]string{"", "enter", "open", "tick", "ticked", "close", "halt", "exit", "write"}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventNames) && k != 0 {
+		return eventNames[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", uint8(k))
+}
+
+// Event is one node event. Node is the key of the node, see
+// `core.Tick.NodeKey`.
+type Event struct {
+	Kind   EventKind `json:"k"`
+	Node   string    `json:"n,omitempty"`
+	Status b3.Status `json:"s,omitempty"` // TickedEvent
+	Write  *Write    `json:"w,omitempty"` // WriteEvent
+}
+
+func (this Event) String() string {
+	switch this.Kind {
+	case TickedEvent:
+		return fmt.Sprintf("%s %s %v", this.Kind, this.Node, this.Status)
+	case WriteEvent:
+		return fmt.Sprintf("%s %s %v", this.Kind, this.Node, this.Write)
+	}
+	return fmt.Sprintf("%s %s", this.Kind, this.Node)
+}
+
+// Write is a blackboard write, or removal, in the global or tree context.
+type Write struct {
+	Key     string          `json:"k"`
+	Tree    string          `json:"t,omitempty"`
+	Value   json.RawMessage `json:"v,omitempty"`
+	Removed bool            `json:"r,omitempty"`
+}
+
+func (this *Write) String() string {
+	key := this.Key
+	if this.Tree != "" {
+		key = "tree:" + key
+	}
+	if this.Removed {
+		return key + " removed"
+	}
+	return key + "=" + string(this.Value)
+}
+
+// Frame is one tick of the tree, or one `BehaviorTree.Halt`.
+type Frame struct {
+	// Time of the tree clock, in Unix nanoseconds.
+	Time int64 `json:"t"`
+	// Halt is true for a `BehaviorTree.Halt` between ticks.
+	Halt   bool      `json:"h,omitempty"`
+	Status b3.Status `json:"s,omitempty"`
+	// Input are the writes made outside of the tree since the previous frame.
+	Input  []Write `json:"in,omitempty"`
+	Events []Event `json:"ev"`
+}
+
+// NodeMeta describes a node seen in the recording.
+type NodeMeta struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+// Recording is everything recorded for one agent.
+type Recording struct {
+	Version int    `json:"version"`
+	Tree    string `json:"tree"` // ID of the recorded tree
+	Title   string `json:"title"`
+	// Start is the blackboard (global and tree contexts) when the recording
+	// started.
+	Start  []Write    `json:"start,omitempty"`
+	Nodes  []NodeMeta `json:"nodes"`
+	Frames []Frame    `json:"frames"`
+}
+
+// Node returns the description of a node key, or a description with only
+// the key if the node was not seen.
+func (this *Recording) Node(key string) NodeMeta {
+	for _, node := range this.Nodes {
+		if node.Key == key {
+			return node
+		}
+	}
+	return NodeMeta{Key: key}
+}
+
+// Save writes the recording, gzipped JSON.
+func (this *Recording) Save(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(this); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func (this *Recording) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := this.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads a recording written by Save.
+func Load(r io.Reader) (*Recording, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var rec Recording
+	if err := json.NewDecoder(zr).Decode(&rec); err != nil {
+		return nil, err
+	}
+	if rec.Version != Version {
+		return nil, fmt.Errorf("record: unsupported recording version %d", rec.Version)
+	}
+	return &rec, nil
+}
+
+func LoadFile(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
@@ -0,0 +1,186 @@
+package record
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+)
+
+// Attack 依赖游戏状态(target)的节点，回放时被替换
+type Attack struct {
+	Action
+}
+
+func (this *Attack) OnTick(tick *Tick) b3.Status {
+	hp := tick.GetTarget().(map[string]int)
+	if hp["enemy"] <= 0 {
+		return b3.SUCCESS
+	}
+	hp["enemy"] -= 4
+	tick.Blackboard.SetMem("lastHit", hp["enemy"])
+	return b3.RUNNING
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func testTree(root string) *BTTreeCfg {
+	return &BTTreeCfg{
+		ID:   "fight",
+		Root: "1",
+		Nodes: map[string]BTNodeCfg{
+			"1": {Id: "1", Name: root, Title: "root", Children: []string{"2", "3"}},
+			"2": {Id: "2", Name: "Attack", Title: "attack"},
+			"3": {Id: "3", Name: "Succeeder", Title: "done"},
+		},
+	}
+}
+
+func record(t *testing.T) *Recording {
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("Attack", new(Attack))
+	tree := CreateBevTreeFromConfig(testTree("MemSequence"), maps)
+	clock := &testClock{time.Unix(100, 0)}
+	tree.SetClock(clock)
+
+	board := NewBlackboard()
+	board.SetMem("name", "orc")
+	recorder := NewRecorder(tree, board)
+	game := map[string]int{"enemy": 10}
+	for i := 0; i < 4; i++ {
+		board.SetMem("frame", i)
+		tree.Tick(game, board)
+		clock.now = clock.now.Add(time.Second)
+	}
+	game["enemy"] = 10
+	tree.Tick(game, board)
+	tree.Halt(board)
+	recorder.Stop()
+	tree.Tick(game, board) // not recorded
+
+	var buf bytes.Buffer
+	if err := recorder.Recording().Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestRecordReplay(t *testing.T) {
+	rec := record(t)
+	if len(rec.Frames) != 6 || !rec.Frames[5].Halt {
+		t.Fatalf("recorded %d frames, want 5 ticks and a halt", len(rec.Frames))
+	}
+	if rec.Frames[0].Status != b3.RUNNING || rec.Frames[3].Status != b3.SUCCESS {
+		t.Errorf("statuses = %v, %v", rec.Frames[0].Status, rec.Frames[3].Status)
+	}
+	if rec.Node("2").Title != "attack" {
+		t.Errorf("node 2 = %+v", rec.Node("2"))
+	}
+
+	// 回放不需要Attack节点和游戏状态
+	replayer, err := NewReplayer(rec, []*BTTreeCfg{testTree("MemSequence")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+	if d := replayer.Run(); d != nil {
+		t.Fatalf("divergence: %v", d)
+	}
+	if v := replayer.Blackboard().GetInt("lastHit", "", ""); v != 6 {
+		t.Errorf("lastHit = %v, want 6", v)
+	}
+	if replayer.Blackboard().GetInt("frame", "", "") != 3 || replayer.Blackboard().GetMem("name") != "orc" {
+		t.Error("inputs were not replayed")
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	rec := record(t)
+	// Priority 在attack成功时结束，不会执行done
+	replayer, err := NewReplayer(rec, []*BTTreeCfg{testTree("Priority")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+	d := replayer.Run()
+	if d == nil {
+		t.Fatal("no divergence")
+	}
+	if d.Frame != 3 || d.Expected == nil || d.Actual == nil || d.Expected.Kind != EnterEvent || d.Expected.Node != "3" ||
+		d.Actual.Kind != TickedEvent || d.Actual.Node != "1" {
+		t.Errorf("divergence = %v", d)
+	}
+}
+
+// Mark 交替写入和删除树的内存
+type Mark struct {
+	Action
+}
+
+func (this *Mark) OnTick(tick *Tick) b3.Status {
+	tree := tick.GetTree().GetID()
+	if tick.Blackboard.Get("mark", tree, "") == nil {
+		tick.Blackboard.SetTree("mark", 1, tree)
+	} else {
+		tick.Blackboard.RemoveTree("mark", tree)
+	}
+	return b3.SUCCESS
+}
+
+func TestReplayTreeRemove(t *testing.T) {
+	cfg := &BTTreeCfg{
+		ID:    "mark",
+		Root:  "1",
+		Nodes: map[string]BTNodeCfg{"1": {Id: "1", Name: "Mark", Title: "mark"}},
+	}
+	maps := b3.NewRegisterStructMaps()
+	maps.Register("Mark", new(Mark))
+	tree := CreateBevTreeFromConfig(cfg, maps)
+	board := NewBlackboard()
+	recorder := NewRecorder(tree, board)
+	for i := 0; i < 3; i++ {
+		tree.Tick(nil, board)
+	}
+	recorder.Stop()
+	rec := recorder.Recording()
+	removed := false
+	for _, e := range rec.Frames[1].Events {
+		removed = removed || e.Write != nil && e.Write.Removed && e.Write.Key == "mark"
+	}
+	if !removed {
+		t.Fatalf("frame 1 = %+v", rec.Frames[1].Events)
+	}
+
+	loader := func(string) *BehaviorTree { return tree }
+	SetSubTreeLoadFunc(loader)
+	defer SetSubTreeLoadFunc(nil)
+	replayer, err := NewReplayer(rec, []*BTTreeCfg{cfg}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 删除树的键回放为删除，不是写入nil
+	if d := replayer.Run(); d != nil {
+		t.Fatalf("divergence: %v", d)
+	}
+	if v := replayer.Blackboard().Get("mark", replayer.Tree().GetID(), ""); v == nil {
+		t.Error("mark was not replayed")
+	}
+	replayer.Close()
+	if GetSubTree("mark") != tree {
+		t.Error("subtree loader not restored")
+	}
+}
@@ -0,0 +1,393 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	b3 "behavior3go"
+	. "behavior3go/config"
+	. "behavior3go/core"
+	. "behavior3go/loader"
+)
+
+// Divergence is the first difference between a recorded frame and its
+// replay.
+type Divergence struct {
+	Frame int
+	// Index of the first different event in the frame.
+	Event    int
+	Expected *Event // nil if the replay has more events
+	Actual   *Event // nil if the replay has less events
+}
+
+func (this *Divergence) String() string {
+	describe := func(e *Event) string {
+		if e == nil {
+			return "nothing"
+		}
+		return e.String()
+	}
+	return fmt.Sprintf("frame %d event %d: recorded %s, replayed %s",
+		this.Frame, this.Event, describe(this.Expected), describe(this.Actual))
+}
+
+// FrameResult is the replay of one frame.
+type FrameResult struct {
+	Frame    int
+	Recorded *Frame
+	Status   b3.Status
+	Events   []Event
+	// Divergence is nil if the replay matches the recording.
+	Divergence *Divergence
+}
+
+// occurrence 叶子节点的一次执行：录制的写入和返回的状态
+type occurrence struct {
+	writes []*Write
+	status b3.Status
+}
+
+// replayClock 返回当前帧录制的时间
+type replayClock struct {
+	now time.Time
+}
+
+func (this *replayClock) Now() time.Time {
+	return this.now
+}
+
+/**
+ * Replayer replays a recording frame by frame. The leaf nodes of the trees
+ * (nodes without children, except `tree` nodes) are replaced by stubs, the
+ * other nodes are created from the built-in nodes and maps.
+ *
+ * The replayer sets the subtree load function (`core.SetSubTreeLoadFunc`)
+ * to its own trees; `Close` restores the previous one.
+**/
+type Replayer struct {
+	BaseTickListener
+	rec   *Recording
+	tree  *BehaviorTree
+	trees map[string]*BehaviorTree
+	bb    *Blackboard
+	clock *replayClock
+	next  int
+
+	// 当前帧中叶子节点的执行，按节点key
+	expect  map[string][]*occurrence
+	ticking bool
+	events  []Event
+	stack   []string
+
+	// 回放之前的子树加载函数，Close时恢复
+	prevLoad func(string) *BehaviorTree
+}
+
+// Stub replaces the leaf nodes in a replay: it redoes the writes and returns
+// the status recorded for the node.
+type Stub struct {
+	Action
+}
+
+func (this *Stub) OnTick(tick *Tick) b3.Status {
+	replayer, ok := tick.GetTarget().(*Replayer)
+	if !ok {
+		return tick.Fail(fmt.Errorf("record.Stub %s ticked outside of a replay", this.GetName()))
+	}
+	return replayer._stubTick(tick, this)
+}
+
+/**
+ * NewReplayer prepares the replay of rec. trees are the configurations of
+ * the recorded tree, first, and of the subtrees it calls; maps provides the
+ * custom composites and decorators, it may be nil.
+**/
+func NewReplayer(rec *Recording, trees []*BTTreeCfg, maps *b3.RegisterStructMaps) (replayer *Replayer, err error) {
+	this := &Replayer{
+		rec:   rec,
+		trees: make(map[string]*BehaviorTree),
+		bb:    NewBlackboard(),
+		clock: &replayClock{},
+	}
+	defer func() {
+		// 加载树时的panic
+		if r := recover(); r != nil {
+			replayer, err = nil, fmt.Errorf("record: %v", r)
+		}
+	}()
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("record: no tree to replay %s", rec.Title)
+	}
+	for _, cfg := range trees {
+		tree := CreateBevTreeFromConfig(cfg, stubMaps(cfg, maps))
+		tree.SetClock(this.clock)
+		tree.AddListener(this)
+		this.trees[cfg.ID] = tree
+		if this.tree == nil {
+			this.tree = tree
+		}
+	}
+	this.prevLoad = GetSubTreeLoadFunc()
+	SetSubTreeLoadFunc(func(id string) *BehaviorTree {
+		return this.trees[id]
+	})
+	this.bb.WatchAll(this.onChange)
+	this._applyWrites(rec.Start)
+	return this, nil
+}
+
+// stubMaps 叶子节点注册为Stub，其他自定义节点使用maps中的类型
+func stubMaps(cfg *BTTreeCfg, maps *b3.RegisterStructMaps) *b3.RegisterStructMaps {
+	leaves := make(map[string]bool)
+	for _, node := range cfg.Nodes {
+		if node.Category == "tree" {
+			continue
+		}
+		isLeaf := len(node.Children) == 0 && node.Child == ""
+		if leaf, seen := leaves[node.Name]; !seen || leaf {
+			leaves[node.Name] = isLeaf
+		}
+	}
+	ext := b3.NewRegisterStructMaps()
+	for name, leaf := range leaves {
+		if leaf {
+			ext.Register(name, new(Stub))
+		} else if maps != nil && maps.CheckElem(name) {
+			node, _ := maps.New(name)
+			ext.Register(name, node)
+		}
+	}
+	return ext
+}
+
+func (this *Replayer) _applyWrites(writes []Write) {
+	for i := range writes {
+		this._applyWrite(&writes[i])
+	}
+}
+
+// _applyWrite 重做一次写入，值是JSON解码的结果(数字为float64)。
+// 树的ID在每次加载时生成，录制的树上下文对应回放的树
+func (this *Replayer) _applyWrite(w *Write) {
+	tree := w.Tree
+	if tree == this.rec.Tree {
+		tree = this.tree.GetID()
+	}
+	if w.Removed {
+		if tree == "" {
+			this.bb.Remove(w.Key)
+		} else {
+			this.bb.RemoveTree(w.Key, tree)
+		}
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(w.Value, &value); err != nil {
+		value = string(w.Value)
+	}
+	this.bb.Set(w.Key, value, tree, "")
+}
+
+// Close restores the subtree load function set before NewReplayer. The
+// replayer can't replay trees calling subtrees afterwards.
+func (this *Replayer) Close() {
+	SetSubTreeLoadFunc(this.prevLoad)
+}
+
+// Tree returns the replayed tree.
+func (this *Replayer) Tree() *BehaviorTree {
+	return this.tree
+}
+
+// Blackboard returns the blackboard of the replay.
+func (this *Replayer) Blackboard() *Blackboard {
+	return this.bb
+}
+
+// Next returns the index of the next frame to replay.
+func (this *Replayer) Next() int {
+	return this.next
+}
+
+// Done tells whether every frame was replayed.
+func (this *Replayer) Done() bool {
+	return this.next >= len(this.rec.Frames)
+}
+
+// Step replays the next frame, nil if every frame was replayed.
+func (this *Replayer) Step() *FrameResult {
+	if this.Done() {
+		return nil
+	}
+	index := this.next
+	frame := &this.rec.Frames[index]
+	this.next++
+
+	this.clock.now = time.Unix(0, frame.Time)
+	this.events = nil
+	this.stack = this.stack[:0]
+	this._expect(frame)
+	this._applyWrites(frame.Input)
+
+	result := &FrameResult{Frame: index, Recorded: frame}
+	if frame.Halt {
+		this.tree.Halt(this.bb)
+	} else {
+		this.ticking = true
+		result.Status = this.tree.Tick(this, this.bb)
+		this.ticking = false
+	}
+	result.Events = this.events
+	result.Divergence = compareEvents(index, frame.Events, this.events)
+	return result
+}
+
+// Run replays the remaining frames and returns the first divergence, nil if
+// the replay matches the recording.
+func (this *Replayer) Run() *Divergence {
+	for !this.Done() {
+		if result := this.Step(); result.Divergence != nil {
+			return result.Divergence
+		}
+	}
+	return nil
+}
+
+// _expect 从录制的事件中取出叶子节点每次执行的写入和状态
+func (this *Replayer) _expect(frame *Frame) {
+	this.expect = make(map[string][]*occurrence)
+	open := make(map[string]*occurrence)
+	for i := range frame.Events {
+		event := &frame.Events[i]
+		switch event.Kind {
+		case EnterEvent:
+			occ := &occurrence{}
+			open[event.Node] = occ
+			this.expect[event.Node] = append(this.expect[event.Node], occ)
+		case WriteEvent:
+			if occ := open[event.Node]; occ != nil {
+				occ.writes = append(occ.writes, event.Write)
+			}
+		case TickedEvent:
+			if occ := open[event.Node]; occ != nil {
+				occ.status = event.Status
+			}
+		}
+	}
+}
+
+func (this *Replayer) _stubTick(tick *Tick, stub *Stub) b3.Status {
+	key := tick.NodeKey(stub)
+	queue := this.expect[key]
+	if len(queue) == 0 || queue[0].status == 0 {
+		// 录制中这个节点没有执行，比较事件时会报告
+		return b3.FAILURE
+	}
+	occ := queue[0]
+	this.expect[key] = queue[1:]
+	// 录制的是黑板中实际写入的key，不经过子树的端口映射
+	for _, w := range occ.writes {
+		this._applyWrite(w)
+	}
+	return occ.status
+}
+
+func compareEvents(frame int, expected, actual []Event) *Divergence {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if i >= len(expected) {
+			return &Divergence{frame, i, nil, &actual[i]}
+		}
+		if i >= len(actual) {
+			return &Divergence{frame, i, &expected[i], nil}
+		}
+		if !sameEvent(&expected[i], &actual[i]) {
+			return &Divergence{frame, i, &expected[i], &actual[i]}
+		}
+	}
+	return nil
+}
+
+func sameEvent(a, b *Event) bool {
+	if a.Kind != b.Kind || a.Node != b.Node || a.Status != b.Status {
+		return false
+	}
+	if a.Kind != WriteEvent {
+		return true
+	}
+	return a.Write.Key == b.Write.Key && a.Write.Tree == b.Write.Tree &&
+		a.Write.Removed == b.Write.Removed && sameJSON(a.Write.Value, b.Write.Value)
+}
+
+// sameJSON 比较两个JSON值，忽略对象字段的顺序
+func sameJSON(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+//------------------------listener-------------------------
+
+func (this *Replayer) _event(tick *Tick, kind EventKind, node IBaseNode, status b3.Status) string {
+	key := tick.NodeKey(node)
+	this.events = append(this.events, Event{Kind: kind, Node: key, Status: status})
+	return key
+}
+
+// onChange 记录tick中的写入，与Recorder相同
+func (this *Replayer) onChange(change BlackboardChange) {
+	if !this.ticking || change.NodeScope != "" {
+		return
+	}
+	write := Write{Key: change.Key, Tree: change.TreeScope, Removed: change.Removed}
+	if write.Tree == this.tree.GetID() {
+		write.Tree = this.rec.Tree
+	}
+	if !change.Removed {
+		write.Value = EncodeValue(change.New)
+	}
+	node := ""
+	if len(this.stack) > 0 {
+		node = this.stack[len(this.stack)-1]
+	}
+	this.events = append(this.events, Event{Kind: WriteEvent, Node: node, Write: &write})
+}
+
+func (this *Replayer) EnterNode(tick *Tick, node IBaseNode) {
+	this.stack = append(this.stack, this._event(tick, EnterEvent, node, 0))
+}
+
+func (this *Replayer) OpenNode(tick *Tick, node IBaseNode) {
+	this._event(tick, OpenEvent, node, 0)
+}
+
+func (this *Replayer) TickNode(tick *Tick, node IBaseNode) {
+	this._event(tick, TickEvent, node, 0)
+}
+
+func (this *Replayer) TickedNode(tick *Tick, node IBaseNode, status b3.Status) {
+	this._event(tick, TickedEvent, node, status)
+}
+
+func (this *Replayer) CloseNode(tick *Tick, node IBaseNode) {
+	this._event(tick, CloseEvent, node, 0)
+}
+
+func (this *Replayer) HaltNode(tick *Tick, node IBaseNode) {
+	this._event(tick, HaltEvent, node, 0)
+}
+
+func (this *Replayer) ExitNode(tick *Tick, node IBaseNode) {
+	this._event(tick, ExitEvent, node, 0)
+	if len(this.stack) > 0 {
+		this.stack = this.stack[:len(this.stack)-1]
+	}
+}